@@ -29,7 +29,27 @@ type WebAPIConfiguration struct {
 	DB    struct {
 		Filename string `conf:"default:/tmp/decaf.db"`
 	}
-	DevRun bool
+	Blocklist struct {
+		Path           string
+		ReloadInterval time.Duration `conf:"default:30s"`
+		Threshold      int           `conf:"default:6"`
+	}
+	Trash struct {
+		Retention     time.Duration `conf:"default:720h"`
+		PurgeInterval time.Duration `conf:"default:1h"`
+	}
+	Scheduler struct {
+		Interval time.Duration `conf:"default:1m"`
+	}
+	Stories struct {
+		ExpiryInterval time.Duration `conf:"default:1m"`
+	}
+	Comments struct {
+		EditWindow time.Duration `conf:"default:15m"`
+	}
+	Reactions []string `conf:"default:❤️;😂;😮;😢;🔥"`
+	Admins    []int64
+	DevRun    bool
 }
 
 // loadConfiguration creates a WebAPIConfiguration starting from flags, environment variables and configuration file.
@@ -121,8 +121,18 @@ func run() error {
 
 	// Create the API router
 	apirouter, err := api.New(api.Config{
-		Logger:   logger,
-		Database: db,
+		Logger:                  logger,
+		Database:                db,
+		BlocklistPath:           cfg.Blocklist.Path,
+		BlocklistReloadInterval: cfg.Blocklist.ReloadInterval,
+		BlocklistThreshold:      cfg.Blocklist.Threshold,
+		TrashRetention:          cfg.Trash.Retention,
+		TrashPurgeInterval:      cfg.Trash.PurgeInterval,
+		SchedulerInterval:       cfg.Scheduler.Interval,
+		StoryExpiryInterval:     cfg.Stories.ExpiryInterval,
+		Reactions:               cfg.Reactions,
+		CommentEditWindow:       cfg.Comments.EditWindow,
+		Admins:                  cfg.Admins,
 	})
 	if err != nil {
 		logger.WithError(err).Error("error creating the API server instance")
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+func (rt *_router) getBlockedUploads(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !rt.admins[token] {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	uploads, err := rt.db.GetBlockedUploads()
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(uploads)
+}
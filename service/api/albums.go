@@ -0,0 +1,436 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Maximum lengths of the texts of an album
+const (
+	maxAlbumTitleLength       = 100
+	maxAlbumDescriptionLength = 1000
+)
+
+// validAlbumSettings checks the settings of an album, filling in the default visibility
+func validAlbumSettings(settings *AlbumSettings) error {
+	settings.Title = strings.TrimSpace(settings.Title)
+	if settings.Title == "" || utf8.RuneCountInString(settings.Title) > maxAlbumTitleLength {
+		return errors.New("the title must be between 1 and 100 characters")
+	}
+	if utf8.RuneCountInString(settings.Description) > maxAlbumDescriptionLength {
+		return errors.New("the description is too long")
+	}
+	switch settings.Visibility {
+	case "":
+		settings.Visibility = database.AlbumPublic
+	case database.AlbumPublic, database.AlbumMembers:
+	default:
+		return errors.New("unknown album visibility")
+	}
+	return nil
+}
+
+// albumRole extracts the album id from the path and returns the role of the user in the album. If the album does not
+// exist it writes the error response and returns false
+func (rt *_router) albumRole(w http.ResponseWriter, p httprouter.Params, token int64) (int64, string, bool) {
+	albumId, err := strconv.ParseInt(p.ByName("albumId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid album ID") {
+		return 0, "", false
+	}
+
+	role, err := rt.db.GetAlbumRole(albumId, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		ReturnNotFoundError(w)
+		return 0, "", false
+	}
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, "", false
+	}
+	return albumId, role, true
+}
+
+// ownedAlbumId is like albumRole, but it also checks that the user owns the album
+func (rt *_router) ownedAlbumId(w http.ResponseWriter, p httprouter.Params, token int64) (int64, bool) {
+	albumId, role, ok := rt.albumRole(w, p, token)
+	if !ok {
+		return 0, false
+	}
+	if role != database.AlbumOwner {
+		ReturnForbiddenMessage(w)
+		return 0, false
+	}
+	return albumId, true
+}
+
+// viewableAlbumId is like albumRole, but it checks that the user can see the album
+func (rt *_router) viewableAlbumId(w http.ResponseWriter, p httprouter.Params, token int64) (int64, bool) {
+	albumId, _, ok := rt.albumRole(w, p, token)
+	if !ok {
+		return 0, false
+	}
+	visible, err := rt.db.CanViewAlbum(albumId, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, false
+	}
+	if !visible {
+		ReturnForbiddenMessage(w)
+		return 0, false
+	}
+	return albumId, true
+}
+
+func (rt *_router) createAlbum(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var settings AlbumSettings
+	if handleError(w, json.NewDecoder(r.Body).Decode(&settings), http.StatusBadRequest, "Invalid album data") {
+		return
+	}
+	if err := validAlbumSettings(&settings); err != nil {
+		ReturnCustomMessage(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	albumId, err := rt.db.CreateAlbum(token, settings.Title, settings.Description, settings.Visibility)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreatedAlbumMessage{AlbumId: albumId})
+}
+
+// getMyAlbums returns the albums of the authenticated user and the albums they are a member of
+func (rt *_router) getMyAlbums(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albums, err := rt.db.GetMyAlbums(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(albums)
+}
+
+// getAlbumsOf returns the albums of a user visible to the authenticated user
+func (rt *_router) getAlbumsOf(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+	owner, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	banned, err := rt.db.CheckBan(owner, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if banned {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	albums, err := rt.db.GetAlbumsOf(token, owner)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(albums)
+}
+
+func (rt *_router) getAlbum(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, ok := rt.viewableAlbumId(w, p, token)
+	if !ok {
+		return
+	}
+
+	album, err := rt.db.GetAlbum(albumId, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(album)
+}
+
+func (rt *_router) updateAlbum(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, ok := rt.ownedAlbumId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var settings AlbumSettings
+	if handleError(w, json.NewDecoder(r.Body).Decode(&settings), http.StatusBadRequest, "Invalid album data") {
+		return
+	}
+	if err := validAlbumSettings(&settings); err != nil {
+		ReturnCustomMessage(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The cover must be one of the photos of the album
+	if settings.CoverPhoto != 0 {
+		_, err := rt.db.GetAlbumPhotoAdder(albumId, settings.CoverPhoto)
+		if errors.Is(err, sql.ErrNoRows) {
+			ReturnCustomMessage(w, "Bad Request: the cover photo is not in the album", http.StatusBadRequest)
+			return
+		}
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+	}
+
+	if handleError(w, rt.db.UpdateAlbum(albumId, settings.Title, settings.Description, settings.Visibility, settings.CoverPhoto), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (rt *_router) deleteAlbum(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, ok := rt.ownedAlbumId(w, p, token)
+	if !ok {
+		return
+	}
+
+	if handleError(w, rt.db.DeleteAlbum(albumId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addAlbumPhoto adds a photo of the authenticated user to an album they own or contribute to
+func (rt *_router) addAlbumPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, role, ok := rt.albumRole(w, p, token)
+	if !ok {
+		return
+	}
+	if role != database.AlbumOwner && role != database.AlbumContributor {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	_, err := rt.db.GetAlbumPhotoAdder(albumId, photoId)
+	if err == nil {
+		ReturnConflictMessage(w)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		ReturnInternalServerError(w, err)
+		return
+	}
+
+	if handleError(w, rt.db.AddAlbumPhoto(albumId, photoId, token), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeAlbumPhoto removes a photo from an album. The owner of the album can remove any photo, the contributors only
+// the photos they added.
+func (rt *_router) removeAlbumPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, role, ok := rt.albumRole(w, p, token)
+	if !ok {
+		return
+	}
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	addedBy, err := rt.db.GetAlbumPhotoAdder(albumId, photoId)
+	if errors.Is(err, sql.ErrNoRows) {
+		ReturnNotFoundError(w)
+		return
+	}
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if role != database.AlbumOwner && addedBy != token {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.RemoveAlbumPhoto(albumId, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setAlbumOrder reorders the photos of an album. The body must list every photo of the album once.
+func (rt *_router) setAlbumOrder(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, ok := rt.ownedAlbumId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var order AlbumOrder
+	if handleError(w, json.NewDecoder(r.Body).Decode(&order), http.StatusBadRequest, "Invalid album order") {
+		return
+	}
+
+	current, err := rt.db.GetAlbumPhotoIds(albumId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	remaining := make(map[int64]bool, len(current))
+	for _, photoId := range current {
+		remaining[photoId] = true
+	}
+	for _, photoId := range order.Photos {
+		if !remaining[photoId] {
+			ReturnCustomMessage(w, "Bad Request: the order must list every photo of the album once", http.StatusBadRequest)
+			return
+		}
+		delete(remaining, photoId)
+	}
+	if len(remaining) != 0 {
+		ReturnCustomMessage(w, "Bad Request: the order must list every photo of the album once", http.StatusBadRequest)
+		return
+	}
+
+	if handleError(w, rt.db.SetAlbumOrder(albumId, order.Photos), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(order)
+}
+
+func (rt *_router) getAlbumMembers(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, ok := rt.viewableAlbumId(w, p, token)
+	if !ok {
+		return
+	}
+
+	members, err := rt.db.GetAlbumMembers(albumId, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(members)
+}
+
+// setAlbumMember adds a user to an album of the authenticated user, or changes their role
+func (rt *_router) setAlbumMember(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, ok := rt.ownedAlbumId(w, p, token)
+	if !ok {
+		return
+	}
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+	user, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+	if user == token {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	var role AlbumRole
+	if handleError(w, json.NewDecoder(r.Body).Decode(&role), http.StatusBadRequest, "Invalid album role") {
+		return
+	}
+	if role.Role != database.AlbumContributor && role.Role != database.AlbumViewer {
+		ReturnCustomMessage(w, "Bad Request: the role must be contributor or viewer", http.StatusBadRequest)
+		return
+	}
+
+	// Users who banned each other cannot share an album
+	for _, pair := range [][2]int64{{token, user}, {user, token}} {
+		banned, err := rt.db.CheckBan(pair[0], pair[1])
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+		if banned {
+			ReturnForbiddenMessage(w)
+			return
+		}
+	}
+
+	if handleError(w, rt.db.SetAlbumMember(albumId, user, role.Role), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(AlbumMember{Username: username, Role: role.Role})
+}
+
+// removeAlbumMember removes a user from an album, along with the photos they added. The owner of the album can remove
+// any member, the members can only leave the album.
+func (rt *_router) removeAlbumMember(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	albumId, role, ok := rt.albumRole(w, p, token)
+	if !ok {
+		return
+	}
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+	user, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+	if role != database.AlbumOwner && user != token {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	memberRole, err := rt.db.GetAlbumRole(albumId, user)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if memberRole != database.AlbumContributor && memberRole != database.AlbumViewer {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if handleError(w, rt.db.RemoveAlbumMember(albumId, user), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
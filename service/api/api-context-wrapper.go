@@ -8,34 +8,57 @@ import (
 
 type httpRouterHandler func(http.ResponseWriter, *http.Request, httprouter.Params, int64)
 
-func (rt *_router) authWrapper(fn httpRouterHandler) func(http.ResponseWriter, *http.Request, httprouter.Params) {
-	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-		w.Header().Set("content-type", "application/json")
+// authenticate extracts the token from the Authorization header and checks that it is active. If not, it writes the
+// error response and returns false
+func (rt *_router) authenticate(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	w.Header().Set("content-type", "application/json")
 
-		// Extract the token from the Authorization header
-		token, err := ExtractToken(r)
-		if err != nil || token == -1 {
-			w.WriteHeader(http.StatusUnauthorized)
-			rt.baseLogger.Errorf("No Token: %v", err)
-			res := Message{
-				Message: "No Token in the Header",
-			}
-			err = json.NewEncoder(w).Encode(res)
-			ReturnInternalServerError(w, err)
+	// Extract the token from the Authorization header
+	token, err := ExtractToken(r)
+	if err != nil || token == -1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		rt.baseLogger.Errorf("No Token: %v", err)
+		res := Message{
+			Message: "No Token in the Header",
+		}
+		err = json.NewEncoder(w).Encode(res)
+		ReturnInternalServerError(w, err)
+		return 0, false
+	}
+
+	// Check if the token is valid
+	if !rt.db.CheckToken(token) {
+		w.WriteHeader(http.StatusNotFound)
+		rt.baseLogger.Errorf("Not Active Token: %v", err)
+		res := Message{
+			Message: "Not Active Token",
+		}
+		err = json.NewEncoder(w).Encode(res)
+		ReturnInternalServerError(w, err)
+		return 0, false
+	}
+	return token, true
+}
+
+// authWrapperNoPath authenticates the request without comparing the token with the path, for the routes where
+// `userId` is the owner of the resource rather than the authenticated user
+func (rt *_router) authWrapperNoPath(fn httpRouterHandler) func(http.ResponseWriter, *http.Request, httprouter.Params) {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token, ok := rt.authenticate(w, r)
+		if !ok {
 			return
 		}
+		fn(w, r, ps, token)
+	}
+}
 
-		// Check if the token is valid
-		if !rt.db.CheckToken(token) {
-			w.WriteHeader(http.StatusNotFound)
-			rt.baseLogger.Errorf("Not Active Token: %v", err)
-			res := Message{
-				Message: "Not Active Token",
-			}
-			err = json.NewEncoder(w).Encode(res)
-			ReturnInternalServerError(w, err)
+func (rt *_router) authWrapper(fn httpRouterHandler) func(http.ResponseWriter, *http.Request, httprouter.Params) {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token, ok := rt.authenticate(w, r)
+		if !ok {
 			return
 		}
+		var err error
 
 		// Prepare to handle path parameters (if they exist)
 		pathParameters := [3]string{"", "", ""}
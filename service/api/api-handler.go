@@ -12,6 +12,16 @@ func (rt *_router) Handler() http.Handler {
 	rt.router.PUT("/user/:userId/update-username", rt.authWrapper(rt.setMyUserName))
 	rt.router.GET("/user/:userId/profile-page/:username", rt.authWrapper(rt.getUserProfile))
 	rt.router.GET("/user/:userId/search/:username", rt.authWrapper(rt.searchUser))
+	rt.router.GET("/user/:userId/watermark", rt.authWrapper(rt.getWatermark))
+	rt.router.PUT("/user/:userId/watermark", rt.authWrapper(rt.setWatermark))
+	rt.router.DELETE("/user/:userId/watermark", rt.authWrapper(rt.deleteWatermark))
+	rt.router.PUT("/user/:userId/watermark/image", rt.authWrapper(rt.setWatermarkImage))
+	rt.router.GET("/user/:userId/photos-of/:username", rt.authWrapper(rt.getPhotosOf))
+	rt.router.GET("/user/:userId/settings/tag-approval", rt.authWrapper(rt.getTagApproval))
+	rt.router.PUT("/user/:userId/settings/tag-approval", rt.authWrapper(rt.setTagApproval))
+	rt.router.GET("/user/:userId/insights", rt.authWrapper(rt.getProfileInsights))
+	rt.router.GET("/user/:userId/notifications", rt.authWrapper(rt.getNotifications))
+	rt.router.POST("/user/:userId/notifications/read", rt.authWrapper(rt.readNotifications))
 
 	// SOCIAL ACTIONS
 
@@ -19,21 +29,113 @@ func (rt *_router) Handler() http.Handler {
 	rt.router.DELETE("/user/:userId/follow/:username", rt.authWrapper(rt.unfollowUser))
 	rt.router.PUT("/user/:userId/ban/:username", rt.authWrapper(rt.banUser))
 	rt.router.DELETE("/user/:userId/ban/:username", rt.authWrapper(rt.unbanUser))
+	rt.router.PUT("/user/:userId/close-friends/:username", rt.authWrapper(rt.addCloseFriend))
+	rt.router.DELETE("/user/:userId/close-friends/:username", rt.authWrapper(rt.removeCloseFriend))
 
 	// PHOTOS INERACTIONS
 
 	rt.router.GET("/user/:userId/photos/", rt.authWrapper(rt.getMyStream))
 	rt.router.POST("/user/:userId/photos/", rt.authWrapper(rt.uploadPhoto))
 	rt.router.GET("/user/:userId/photos/:photoId/", rt.authWrapper(rt.getPhoto))
+	rt.router.GET("/user/:userId/photos/:photoId/poster", rt.authWrapper(rt.getPhotoPoster))
 	rt.router.DELETE("/user/:userId/photos/:photoId/", rt.authWrapper(rt.deletePhoto))
+	rt.router.PATCH("/user/:userId/photos/:photoId/", rt.authWrapper(rt.editPost))
+	rt.router.PUT("/user/:userId/photos/:photoId/image", rt.authWrapper(rt.replacePhotoImage))
+	rt.router.GET("/user/:userId/photos/:photoId/history/", rt.authWrapper(rt.getPhotoHistory))
+	rt.router.GET("/user/:userId/photos/:photoId/history/:revision", rt.authWrapper(rt.getRevisionImage))
+	rt.router.PUT("/user/:userId/photos/:photoId/visibility", rt.authWrapper(rt.setPhotoVisibility))
+	rt.router.PUT("/user/:userId/photos/:photoId/caption", rt.authWrapper(rt.setCaption))
+	rt.router.PUT("/user/:userId/photos/:photoId/pin", rt.authWrapper(rt.pinPhoto))
+	rt.router.DELETE("/user/:userId/photos/:photoId/pin", rt.authWrapper(rt.unpinPhoto))
+	rt.router.GET("/user/:userId/photos/:photoId/insights", rt.authWrapper(rt.getPhotoInsights))
+	rt.router.GET("/user/:userId/photos/:photoId/people", rt.authWrapper(rt.getPhotoPeople))
+	rt.router.PUT("/user/:userId/photos/:photoId/people/:username", rt.authWrapper(rt.tagPerson))
+	rt.router.DELETE("/user/:userId/photos/:photoId/people/:username", rt.authWrapper(rt.untagPerson))
+	rt.router.GET("/user/:userId/tagged-photos/pending", rt.authWrapper(rt.getPendingTaggedPhotos))
+	rt.router.POST("/user/:userId/tagged-photos/:photoId/approve", rt.authWrapper(rt.approvePersonTag))
+	rt.router.PUT("/user/:userId/photos/:photoId/location", rt.authWrapper(rt.setPhotoLocation))
+	rt.router.DELETE("/user/:userId/photos/:photoId/location", rt.authWrapper(rt.clearPhotoLocation))
+	rt.router.PUT("/user/:userId/photos/:photoId/edit", rt.authWrapper(rt.editPhoto))
+	rt.router.DELETE("/user/:userId/photos/:photoId/edit", rt.authWrapper(rt.revertPhoto))
+	rt.router.PUT("/user/:userId/reposts/:photoId", rt.authWrapper(rt.repostPhoto))
+	rt.router.DELETE("/user/:userId/reposts/:photoId", rt.authWrapper(rt.removeRepost))
+	rt.router.GET("/user/:userId/drafts/", rt.authWrapper(rt.getDrafts))
+	rt.router.POST("/user/:userId/drafts/:photoId/publish", rt.authWrapper(rt.publishDraft))
+	rt.router.GET("/user/:userId/trash/", rt.authWrapper(rt.getTrash))
+	rt.router.GET("/user/:userId/trash/:photoId", rt.authWrapper(rt.getTrashedPhoto))
+	rt.router.POST("/user/:userId/trash/:photoId/restore", rt.authWrapper(rt.restorePhoto))
+	rt.router.DELETE("/user/:userId/trash/:photoId", rt.authWrapper(rt.purgePhoto))
+	rt.router.GET("/user/:userId/map/:username", rt.authWrapper(rt.getUserMap))
+	rt.router.GET("/photos/near", rt.authWrapper(rt.getPhotosNear))
+	rt.router.GET("/reactions", rt.authWrapper(rt.getReactionSet))
+	rt.router.GET("/user/:userId/photos/:photoId/reactions/", rt.authWrapperNoPath(rt.getReactions))
+	rt.router.PUT("/user/:userId/photos/:photoId/reactions/:authenticatedUserId", rt.authWrapper(rt.reactToPhoto))
+	rt.router.DELETE("/user/:userId/photos/:photoId/reactions/:authenticatedUserId", rt.authWrapper(rt.removeReaction))
+	rt.router.GET("/user/:userId/photos/:photoId/likes/", rt.authWrapperNoPath(rt.getLikes))
 	rt.router.PUT("/user/:userId/photos/:photoId/likes/:authenticatedUserId", rt.authWrapper(rt.likePhoto))
 	rt.router.DELETE("/user/:userId/photos/:photoId/likes/:authenticatedUserId", rt.authWrapper(rt.unlikePhoto))
 
 
+	// ALBUMS
+	rt.router.GET("/user/:userId/albums/", rt.authWrapper(rt.getMyAlbums))
+	rt.router.POST("/user/:userId/albums/", rt.authWrapper(rt.createAlbum))
+	rt.router.GET("/user/:userId/albums-of/:username", rt.authWrapper(rt.getAlbumsOf))
+	rt.router.GET("/user/:userId/albums/:albumId", rt.authWrapper(rt.getAlbum))
+	rt.router.PUT("/user/:userId/albums/:albumId", rt.authWrapper(rt.updateAlbum))
+	rt.router.DELETE("/user/:userId/albums/:albumId", rt.authWrapper(rt.deleteAlbum))
+	rt.router.PUT("/user/:userId/albums/:albumId/order", rt.authWrapper(rt.setAlbumOrder))
+	rt.router.PUT("/user/:userId/albums/:albumId/photos/:photoId", rt.authWrapper(rt.addAlbumPhoto))
+	rt.router.DELETE("/user/:userId/albums/:albumId/photos/:photoId", rt.authWrapper(rt.removeAlbumPhoto))
+	rt.router.GET("/user/:userId/albums/:albumId/members/", rt.authWrapper(rt.getAlbumMembers))
+	rt.router.PUT("/user/:userId/albums/:albumId/members/:username", rt.authWrapper(rt.setAlbumMember))
+	rt.router.DELETE("/user/:userId/albums/:albumId/members/:username", rt.authWrapper(rt.removeAlbumMember))
+
+	// SAVED PHOTOS
+	rt.router.GET("/user/:userId/saved/", rt.authWrapper(rt.getSavedPhotos))
+	rt.router.PUT("/user/:userId/saved/:photoId", rt.authWrapper(rt.savePhoto))
+	rt.router.DELETE("/user/:userId/saved/:photoId", rt.authWrapper(rt.unsavePhoto))
+	rt.router.GET("/user/:userId/collections/", rt.authWrapper(rt.getCollections))
+	rt.router.POST("/user/:userId/collections/", rt.authWrapper(rt.createCollection))
+	rt.router.GET("/user/:userId/collections/:collectionId", rt.authWrapper(rt.getCollectionPhotos))
+	rt.router.PUT("/user/:userId/collections/:collectionId", rt.authWrapper(rt.renameCollection))
+	rt.router.DELETE("/user/:userId/collections/:collectionId", rt.authWrapper(rt.deleteCollection))
+	rt.router.PUT("/user/:userId/collections/:collectionId/photos/:photoId", rt.authWrapper(rt.addCollectionPhoto))
+	rt.router.DELETE("/user/:userId/collections/:collectionId/photos/:photoId", rt.authWrapper(rt.removeCollectionPhoto))
+
+	// STORIES
+	rt.router.GET("/user/:userId/stories/", rt.authWrapper(rt.getMyStories))
+	rt.router.POST("/user/:userId/stories/", rt.authWrapper(rt.uploadStory))
+	rt.router.DELETE("/user/:userId/stories/:storyId", rt.authWrapper(rt.deleteStory))
+	rt.router.GET("/user/:userId/stories/:storyId/viewers", rt.authWrapper(rt.getStoryViewers))
+	rt.router.GET("/user/:userId/story-tray", rt.authWrapper(rt.getStoryTray))
+	rt.router.GET("/user/:userId/stories-of/:username", rt.authWrapper(rt.getStoriesOf))
+	rt.router.GET("/user/:userId/stories-of/:username/:storyId", rt.authWrapper(rt.getStory))
+	rt.router.GET("/user/:userId/story-archive/", rt.authWrapper(rt.getStoryArchive))
+	rt.router.GET("/user/:userId/settings/story-archive", rt.authWrapper(rt.getStoryArchiveSetting))
+	rt.router.PUT("/user/:userId/settings/story-archive", rt.authWrapper(rt.setStoryArchiveSetting))
+
+	// HASHTAGS
+	rt.router.GET("/tags/:tag", rt.authWrapper(rt.getTag))
+	rt.router.GET("/tags/:tag/photos", rt.authWrapper(rt.getTagPhotos))
+
 	// COMMENTS
 	rt.router.GET("/user/:userId/photos/:photoId/comments/", rt.authWrapperNoPath(rt.getPhotoComments))
 	rt.router.POST("/user/:userId/photos/:photoId/comments/", rt.authWrapperNoPath(rt.commentPhoto))
 	rt.router.DELETE("/user/:userId/photos/:photoId/comments/:commentId", rt.authWrapperNoPath(rt.deleteComment))
+	rt.router.PATCH("/user/:userId/photos/:photoId/comments/:commentId", rt.authWrapperNoPath(rt.editComment))
+	rt.router.GET("/user/:userId/photos/:photoId/comments/:commentId/replies/", rt.authWrapperNoPath(rt.getCommentReplies))
+	rt.router.GET("/user/:userId/photos/:photoId/comments/:commentId/history/", rt.authWrapperNoPath(rt.getCommentHistory))
+	rt.router.PUT("/user/:userId/photos/:photoId/comments/:commentId/likes/:authenticatedUserId", rt.authWrapperNoPath(rt.likeComment))
+	rt.router.DELETE("/user/:userId/photos/:photoId/comments/:commentId/likes/:authenticatedUserId", rt.authWrapperNoPath(rt.unlikeComment))
+	rt.router.GET("/user/:userId/photos/:photoId/comment-settings", rt.authWrapper(rt.getCommentSettings))
+	rt.router.PUT("/user/:userId/photos/:photoId/comment-settings", rt.authWrapper(rt.setCommentSettings))
+	rt.router.GET("/user/:userId/settings/comment-filter", rt.authWrapper(rt.getCommentFilter))
+	rt.router.PUT("/user/:userId/settings/comment-filter", rt.authWrapper(rt.setCommentFilter))
+	rt.router.GET("/user/:userId/comment-review/", rt.authWrapper(rt.getCommentReview))
+	rt.router.PUT("/user/:userId/comment-review/:commentId", rt.authWrapper(rt.approveComment))
+
+	// ADMINISTRATION
+	rt.router.GET("/admin/blocked-uploads", rt.authWrapper(rt.getBlockedUploads))
 
 	return rt.router
 }
\ No newline at end of file
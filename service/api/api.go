@@ -39,9 +39,21 @@ package api
 import (
 	"WasaPhoto/service/database"
 	"errors"
+	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"sync"
+	"time"
+)
+
+// Intervals of the background tasks used when they are not set in Config
+const (
+	// defaultSchedulerInterval is how often the scheduled photos are checked
+	defaultSchedulerInterval = time.Minute
+
+	// defaultStoryExpiryInterval is how often the expired stories are checked
+	defaultStoryExpiryInterval = time.Minute
 )
 
 // Config is used to provide dependencies and configuration to the New function.
@@ -51,6 +63,39 @@ type Config struct {
 
 	// Database is the instance of database.AppDatabase where data are saved
 	Database database.AppDatabase
+
+	// BlocklistPath is the file with the hashes of the prohibited images. Leave it empty to disable the blocklist
+	BlocklistPath string
+
+	// BlocklistReloadInterval is how often the blocklist file is checked for changes
+	BlocklistReloadInterval time.Duration
+
+	// BlocklistThreshold is the maximum Hamming distance between two perceptual hashes to consider them a match
+	BlocklistThreshold int
+
+	// TrashRetention is how long deleted photos are kept in the trash before being purged. Zero disables the purge
+	TrashRetention time.Duration
+
+	// TrashPurgeInterval is how often the trash is checked for expired photos
+	TrashPurgeInterval time.Duration
+
+	// SchedulerInterval is how often the scheduled photos are checked for publication. Leave it zero to use
+	// defaultSchedulerInterval
+	SchedulerInterval time.Duration
+
+	// StoryExpiryInterval is how often the expired stories are archived or deleted. Leave it zero to use
+	// defaultStoryExpiryInterval
+	StoryExpiryInterval time.Duration
+
+	// Reactions are the reactions users can choose from. It must include database.ReactionLike, used by the like
+	// endpoints. Leave it empty to use database.DefaultReactions
+	Reactions []string
+
+	// CommentEditWindow is how long after posting a comment its author can edit it. Zero disables comment editing
+	CommentEditWindow time.Duration
+
+	// Admins are the identifiers of the users allowed to use the administration endpoints
+	Admins []int64
 }
 
 // Router is the package API interface representing an API handler builder
@@ -78,11 +123,81 @@ func New(cfg Config) (Router, error) {
 	router.RedirectTrailingSlash = false
 	router.RedirectFixedPath = false
 
-	return &_router{
-		router:     router,
-		baseLogger: cfg.Logger,
-		db:         cfg.Database,
-	}, nil
+	admins := make(map[int64]bool, len(cfg.Admins))
+	for _, admin := range cfg.Admins {
+		admins[admin] = true
+	}
+
+	reactions := cfg.Reactions
+	if len(reactions) == 0 {
+		reactions = database.DefaultReactions
+	}
+	hasLike := false
+	for _, reaction := range reactions {
+		hasLike = hasLike || reaction == database.ReactionLike
+	}
+	if !hasLike {
+		return nil, errors.New("reactions must include " + database.ReactionLike)
+	}
+
+	rt := &_router{
+		router:            router,
+		baseLogger:        cfg.Logger,
+		db:                cfg.Database,
+		admins:            admins,
+		reactions:         reactions,
+		commentEditWindow: cfg.CommentEditWindow,
+		renditions:        newRenditionCache(renditionCacheSize),
+		done:              make(chan struct{}),
+	}
+
+	// Check the intervals of the background tasks before starting any, so that no task is left running when New fails
+	if cfg.BlocklistPath != "" && cfg.BlocklistReloadInterval <= 0 {
+		return nil, errors.New("blocklist reload interval must be positive")
+	}
+	if cfg.TrashRetention > 0 && cfg.TrashPurgeInterval <= 0 {
+		return nil, errors.New("trash purge interval must be positive")
+	}
+	schedulerInterval := cfg.SchedulerInterval
+	if schedulerInterval == 0 {
+		schedulerInterval = defaultSchedulerInterval
+	}
+	if schedulerInterval < 0 {
+		return nil, errors.New("scheduler interval must be positive")
+	}
+	storyExpiryInterval := cfg.StoryExpiryInterval
+	if storyExpiryInterval == 0 {
+		storyExpiryInterval = defaultStoryExpiryInterval
+	}
+	if storyExpiryInterval < 0 {
+		return nil, errors.New("story expiry interval must be positive")
+	}
+
+	// Load the image blocklist, kept in sync with the file below
+	if cfg.BlocklistPath != "" {
+		rt.blocklist = newHashBlocklist(cfg.BlocklistPath, cfg.BlocklistThreshold)
+		if _, err := rt.blocklist.reloadIfChanged(); err != nil {
+			return nil, fmt.Errorf("loading the image blocklist: %w", err)
+		}
+		rt.workers.Add(1)
+		go rt.watchBlocklist(cfg.BlocklistReloadInterval)
+	}
+
+	// Permanently delete the photos that stayed in the trash longer than the retention period
+	if cfg.TrashRetention > 0 {
+		rt.workers.Add(1)
+		go rt.purgeTrash(cfg.TrashRetention, cfg.TrashPurgeInterval)
+	}
+
+	// Publish the scheduled photos when their time comes
+	rt.workers.Add(1)
+	go rt.publishScheduled(schedulerInterval)
+
+	// Archive or delete the stories when they expire
+	rt.workers.Add(1)
+	go rt.expireStories(storyExpiryInterval)
+
+	return rt, nil
 }
 
 type _router struct {
@@ -93,4 +208,25 @@ type _router struct {
 	baseLogger logrus.FieldLogger
 
 	db database.AppDatabase
+
+	// blocklist contains the hashes of the images that cannot be uploaded, nil if the blocklist is disabled
+	blocklist *hashBlocklist
+
+	// admins is the set of users allowed to use the administration endpoints
+	admins map[int64]bool
+
+	// reactions are the reactions users can choose from
+	reactions []string
+
+	// commentEditWindow is how long after posting a comment its author can edit it
+	commentEditWindow time.Duration
+
+	// renditions are the most recently served renditions of the photos
+	renditions *renditionCache
+
+	// done is closed when the router is closed, to stop the background workers
+	done chan struct{}
+
+	// workers tracks the running background workers
+	workers sync.WaitGroup
 }
\ No newline at end of file
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"WasaPhoto/service/imaging"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Match types recorded in the blocked uploads audit table
+const (
+	blockMatchExact      = "sha256"
+	blockMatchPerceptual = "phash"
+)
+
+// hashBlocklist holds the prohibited image hashes loaded from the operator-managed file.
+//
+// The file contains one entry per line, either `sha256:<64 hex digits>` for an exact match or `phash:<16 hex digits>`
+// for a perceptual match. Empty lines and lines starting with `#` are ignored.
+type hashBlocklist struct {
+	mu         sync.RWMutex
+	exact      map[string]bool
+	perceptual []uint64
+
+	path      string
+	threshold int
+	modTime   time.Time
+	size      int64
+}
+
+func newHashBlocklist(path string, threshold int) *hashBlocklist {
+	return &hashBlocklist{
+		exact:     map[string]bool{},
+		path:      path,
+		threshold: threshold,
+	}
+}
+
+// reloadIfChanged reads the blocklist file again if its modification time or size changed since the last load. It
+// returns true if the list has been reloaded.
+func (bl *hashBlocklist) reloadIfChanged() (bool, error) {
+	info, err := os.Stat(bl.path)
+	if err != nil {
+		return false, err
+	}
+
+	bl.mu.RLock()
+	unchanged := info.ModTime().Equal(bl.modTime) && info.Size() == bl.size
+	bl.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	fp, err := os.Open(bl.path)
+	if err != nil {
+		return false, err
+	}
+	defer fp.Close()
+
+	exact := map[string]bool{}
+	var perceptual []uint64
+	scanner := bufio.NewScanner(fp)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return false, fmt.Errorf("blocklist line %d: unrecognized entry %q", line, entry)
+		}
+		kind, value := parts[0], strings.ToLower(strings.TrimSpace(parts[1]))
+		switch {
+		case kind == blockMatchExact && len(value) == sha256.Size*2:
+			if _, err := hex.DecodeString(value); err != nil {
+				return false, fmt.Errorf("blocklist line %d: %w", line, err)
+			}
+			exact[value] = true
+		case kind == blockMatchPerceptual:
+			hash, err := strconv.ParseUint(value, 16, 64)
+			if err != nil {
+				return false, fmt.Errorf("blocklist line %d: %w", line, err)
+			}
+			perceptual = append(perceptual, hash)
+		default:
+			return false, fmt.Errorf("blocklist line %d: unrecognized entry %q", line, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	bl.mu.Lock()
+	bl.exact = exact
+	bl.perceptual = perceptual
+	bl.modTime = info.ModTime()
+	bl.size = info.Size()
+	bl.mu.Unlock()
+	return true, nil
+}
+
+// match checks the image against the blocklist. If the image is prohibited it returns the match type and the hash of
+// the image that matched.
+func (bl *hashBlocklist) match(image []byte) (string, string, bool) {
+	// The lists are replaced and never modified by reloadIfChanged, so they can be read after releasing the lock,
+	// without blocking the reloads while the image is hashed
+	bl.mu.RLock()
+	exact, perceptual := bl.exact, bl.perceptual
+	bl.mu.RUnlock()
+
+	sum := sha256.Sum256(image)
+	digest := hex.EncodeToString(sum[:])
+	if exact[digest] {
+		return blockMatchExact, digest, true
+	}
+
+	if len(perceptual) == 0 {
+		return "", "", false
+	}
+
+	// Images that cannot be decoded can only be matched exactly
+	phash, err := imaging.PerceptualHash(image)
+	if err != nil {
+		return "", "", false
+	}
+	for _, blocked := range perceptual {
+		if imaging.HammingDistance(phash, blocked) <= bl.threshold {
+			return blockMatchPerceptual, fmt.Sprintf("%016x", phash), true
+		}
+	}
+	return "", "", false
+}
+
+// checkBlocklist rejects the images prohibited by the operator and keeps track of the attempt. The image must have been
+// read by readImage, which bounds the cost of decoding it for the perceptual hash. If the image is blocked, it writes
+// the error response and returns false
+func (rt *_router) checkBlocklist(w http.ResponseWriter, token int64, image []byte) bool {
+	if rt.blocklist == nil {
+		return true
+	}
+	matchType, hash, blocked := rt.blocklist.match(image)
+	if !blocked {
+		return true
+	}
+	if handleError(w, rt.db.AddBlockedUpload(token, matchType, hash), http.StatusInternalServerError, "") {
+		return false
+	}
+	ReturnImageBlockedMessage(w)
+	return false
+}
+
+// watchBlocklist reloads the blocklist every interval until the router is closed.
+func (rt *_router) watchBlocklist(interval time.Duration) {
+	defer rt.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rt.done:
+			return
+		case <-ticker.C:
+			reloaded, err := rt.blocklist.reloadIfChanged()
+			if err != nil {
+				rt.baseLogger.WithError(err).Warning("error reloading the image blocklist")
+			} else if reloaded {
+				rt.baseLogger.Info("image blocklist reloaded")
+			}
+		}
+	}
+}
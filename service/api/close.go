@@ -2,5 +2,7 @@ package api
 
 // Close should close everything opened in the lifecycle of the `_router`; for example, background goroutines.
 func (rt *_router) Close() error {
+	close(rt.done)
+	rt.workers.Wait()
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Limits of the keyword filter of the comments
+const (
+	maxFilterKeywords     = 100
+	maxFilterKeywordRunes = 50
+)
+
+// validCommentsMode checks if the string is one of the settings of who can comment a photo
+func validCommentsMode(mode string) bool {
+	switch mode {
+	case database.CommentsEveryone, database.CommentsFollowers, database.CommentsOff:
+		return true
+	}
+	return false
+}
+
+// canComment checks if the user can comment the photo according to its settings. The owner can always comment their
+// photos. If not, it writes the error response and returns false
+func (rt *_router) canComment(w http.ResponseWriter, token int64, photoId int64) bool {
+	owner, err := rt.db.GetPhotoOwner(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return false
+	}
+	if owner == token {
+		return true
+	}
+
+	mode, err := rt.db.GetCommentsMode(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return false
+	}
+	switch mode {
+	case database.CommentsOff:
+		ReturnCustomMessage(w, "Forbidden: comments are turned off for this photo", http.StatusForbidden)
+		return false
+	case database.CommentsFollowers:
+		follower, err := rt.db.CheckFollow(token, owner)
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return false
+		}
+		if !follower {
+			ReturnCustomMessage(w, "Forbidden: only the followers of the author can comment this photo", http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+func (rt *_router) getCommentSettings(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	mode, err := rt.db.GetCommentsMode(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(CommentSettings{Mode: mode})
+}
+
+// setCommentSettings changes who can comment a photo of the authenticated user. The existing comments are kept
+func (rt *_router) setCommentSettings(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var settings CommentSettings
+	if handleError(w, json.NewDecoder(r.Body).Decode(&settings), http.StatusBadRequest, "Invalid comment settings data") {
+		return
+	}
+	if !validCommentsMode(settings.Mode) {
+		ReturnCustomMessage(w, "Bad Request: invalid comments mode", http.StatusBadRequest)
+		return
+	}
+
+	if handleError(w, rt.db.SetCommentsMode(photoId, settings.Mode), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (rt *_router) getCommentFilter(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	keywords, err := rt.db.GetCommentFilter(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(CommentFilter{Keywords: keywords})
+}
+
+// setCommentFilter replaces the keywords that hide the new comments on the photos of the authenticated user until
+// they are reviewed. Keywords are matched ignoring the case
+func (rt *_router) setCommentFilter(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var filter CommentFilter
+	if handleError(w, json.NewDecoder(r.Body).Decode(&filter), http.StatusBadRequest, "Invalid comment filter data") {
+		return
+	}
+
+	keywords := []string{}
+	seen := map[string]bool{}
+	for _, keyword := range filter.Keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" || seen[keyword] {
+			continue
+		}
+		if len([]rune(keyword)) > maxFilterKeywordRunes {
+			ReturnCustomMessage(w, "Bad Request: a keyword is too long", http.StatusBadRequest)
+			return
+		}
+		seen[keyword] = true
+		keywords = append(keywords, keyword)
+	}
+	if len(keywords) > maxFilterKeywords {
+		ReturnCustomMessage(w, "Bad Request: too many keywords", http.StatusBadRequest)
+		return
+	}
+
+	if handleError(w, rt.db.SetCommentFilter(token, keywords), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(CommentFilter{Keywords: keywords})
+}
+
+// getCommentReview returns the comments hidden by the keyword filter on the photos of the authenticated user, which
+// can be approved or deleted
+func (rt *_router) getCommentReview(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	comments, err := rt.db.GetHiddenComments(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(comments)
+}
+
+// approveComment shows to everyone a comment hidden by the keyword filter on a photo of the authenticated user
+func (rt *_router) approveComment(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	commentId, err := strconv.ParseInt(p.ByName("commentId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid comment ID") {
+		return
+	}
+
+	hidden, err := rt.db.CheckHiddenComment(token, commentId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !hidden {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if handleError(w, rt.db.ApproveComment(commentId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the replies returned by getCommentReplies
+const (
+	defaultRepliesLimit = 20
+	maxRepliesLimit     = 100
+)
+
+// checkReplyParent checks that a new comment of the user on the photo can reply to the given comment: it must be on the
+// same photo, visible to the user, not deleted, and not nested too deep. The comments hidden from the user by the
+// keyword filter are treated as missing. If not, it writes the error response and returns false
+func (rt *_router) checkReplyParent(w http.ResponseWriter, token int64, photoId int64, parentId int64) bool {
+	parent, err := rt.db.GetComment(parentId, token)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && parent.Photo != photoId) {
+		ReturnCustomMessage(w, "Bad Request: the parent comment is not on this photo", http.StatusBadRequest)
+		return false
+	}
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return false
+	}
+
+	_, depth, deleted, err := rt.db.GetCommentDepth(parentId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return false
+	}
+	if deleted {
+		ReturnCustomMessage(w, "Bad Request: the parent comment was deleted", http.StatusBadRequest)
+		return false
+	}
+	if depth >= database.MaxReplyDepth {
+		ReturnCustomMessage(w, "Bad Request: replies cannot be nested deeper", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// visibleComment extracts the photo and comment ids from the path and returns the comment, checking that it is on the
+// photo and that the user can see the photo. If not, it writes the error response and returns false
+func (rt *_router) visibleComment(w http.ResponseWriter, p httprouter.Params, token int64) (database.FullDataComment, bool) {
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return database.FullDataComment{}, false
+	}
+
+	commentId, err := strconv.ParseInt(p.ByName("commentId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid comment ID") {
+		return database.FullDataComment{}, false
+	}
+
+	exists, err := rt.db.CheckPhotoExistence(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return database.FullDataComment{}, false
+	}
+	if !exists {
+		ReturnNotFoundError(w)
+		return database.FullDataComment{}, false
+	}
+
+	if !rt.canViewPhoto(w, token, photoId) {
+		return database.FullDataComment{}, false
+	}
+
+	comment, err := rt.db.GetComment(commentId, token)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && comment.Photo != photoId) {
+		ReturnNotFoundError(w)
+		return database.FullDataComment{}, false
+	}
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return database.FullDataComment{}, false
+	}
+	return comment, true
+}
+
+// getCommentReplies returns a page of the direct replies to a comment of a photo
+func (rt *_router) getCommentReplies(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	comment, ok := rt.visibleComment(w, p, token)
+	if !ok {
+		return
+	}
+
+	var err error
+	offset := 0
+	if value := r.URL.Query().Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			ReturnCustomMessage(w, "Bad Request: invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultRepliesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxRepliesLimit {
+			ReturnCustomMessage(w, "Bad Request: invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	replies, err := rt.db.GetCommentReplies(comment.Id, token, offset, limit)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(replies)
+}
+
+// editComment changes the content of a comment of the authenticated user, as long as it was posted within the edit
+// window. The previous content is kept in the history of the comment
+func (rt *_router) editComment(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	comment, ok := rt.visibleComment(w, p, token)
+	if !ok {
+		return
+	}
+
+	// Tombstones have no owner, so they cannot be edited
+	if owner, _ := rt.db.GetCommentOwner(comment.Id); owner != token {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, comment.CreatedAt)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if rt.commentEditWindow <= 0 || globaltime.Now().After(createdAt.Add(rt.commentEditWindow)) {
+		ReturnCustomMessage(w, "Forbidden: the comment can no longer be edited", http.StatusForbidden)
+		return
+	}
+
+	var edit Comment
+	if handleError(w, json.NewDecoder(r.Body).Decode(&edit), http.StatusBadRequest, "Invalid comment data") {
+		return
+	}
+	if strings.TrimSpace(edit.Comment) == "" {
+		ReturnCustomMessage(w, "Bad Request: the comment is empty", http.StatusBadRequest)
+		return
+	}
+
+	if handleError(w, rt.db.EditComment(comment.Id, edit.Comment), http.StatusInternalServerError, "") {
+		return
+	}
+
+	comment, err = rt.db.GetComment(comment.Id, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(comment)
+}
+
+// getCommentHistory returns the previous versions of a comment
+func (rt *_router) getCommentHistory(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	comment, ok := rt.visibleComment(w, p, token)
+	if !ok {
+		return
+	}
+
+	revisions, err := rt.db.GetCommentHistory(comment.Id)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// likableComment returns the comment in the path like visibleComment, also checking that the user in the path is the
+// authenticated one, which the routes with the photo owner and the comment in the path cannot leave to authWrapper
+func (rt *_router) likableComment(w http.ResponseWriter, p httprouter.Params, token int64) (database.FullDataComment, bool) {
+	authenticatedUserId, err := strconv.ParseInt(p.ByName("authenticatedUserId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid user ID") {
+		return database.FullDataComment{}, false
+	}
+	if authenticatedUserId != token {
+		ReturnForbiddenMessage(w)
+		return database.FullDataComment{}, false
+	}
+
+	comment, ok := rt.visibleComment(w, p, token)
+	if !ok {
+		return database.FullDataComment{}, false
+	}
+
+	// Tombstones cannot be liked, and lose their likes when the comment is deleted
+	if comment.Deleted {
+		ReturnNotFoundError(w)
+		return database.FullDataComment{}, false
+	}
+	return comment, true
+}
+
+func (rt *_router) likeComment(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	comment, ok := rt.likableComment(w, p, token)
+	if !ok {
+		return
+	}
+
+	if comment.IsLiked {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.LikeComment(token, comment.Id), http.StatusInternalServerError, "") {
+		return
+	}
+
+	ReturnCreatedMessage(w)
+}
+
+func (rt *_router) unlikeComment(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	comment, ok := rt.likableComment(w, p, token)
+	if !ok {
+		return
+	}
+
+	if !comment.IsLiked {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.UnlikeComment(token, comment.Id), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"WasaPhoto/service/globaltime"
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// parsePublishTime parses an RFC 3339 publication time, which must be in the future
+func parsePublishTime(value string) (time.Time, error) {
+	publishAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return publishAt, err
+	}
+	if !publishAt.After(globaltime.Now()) {
+		return publishAt, errors.New("the publication time must be in the future")
+	}
+	return publishAt, nil
+}
+
+func (rt *_router) getDrafts(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photos, err := rt.db.GetDrafts(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// publishDraft publishes a draft right away, or schedules it if the body contains a publication time
+func (rt *_router) publishDraft(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	var schedule PublishSchedule
+	if r.ContentLength != 0 {
+		if handleError(w, json.NewDecoder(r.Body).Decode(&schedule), http.StatusBadRequest, "Invalid schedule data") {
+			return
+		}
+	}
+
+	draft, err := rt.db.CheckDraft(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !draft {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if schedule.PublishAt == "" {
+		if handleError(w, rt.db.PublishPhoto(photoId), http.StatusInternalServerError, "") {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	publishAt, err := parsePublishTime(schedule.PublishAt)
+	if handleError(w, err, http.StatusBadRequest, "Invalid publication time") {
+		return
+	}
+	if handleError(w, rt.db.SchedulePhoto(photoId, publishAt), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// publishScheduled publishes, every interval, the photos whose publication time has passed according to
+// globaltime.Now. It runs until the router is closed.
+func (rt *_router) publishScheduled(interval time.Duration) {
+	defer rt.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rt.done:
+			return
+		case <-ticker.C:
+			published, err := rt.db.PublishScheduledPhotos(globaltime.Now())
+			if err != nil {
+				rt.baseLogger.WithError(err).Warning("error publishing the scheduled photos")
+			} else if published > 0 {
+				rt.baseLogger.Infof("published %d scheduled photos", published)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"WasaPhoto/service/imaging"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// editPhoto stores the edit recipe of a photo. The original image is kept untouched
+func (rt *_router) editPhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var recipe imaging.Recipe
+	if handleError(w, json.NewDecoder(r.Body).Decode(&recipe), http.StatusBadRequest, "Invalid edit data") {
+		return
+	}
+
+	// The recipe must fit the original image
+	image, err := rt.db.GetImage(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	img, _, err := imaging.Decode(image)
+	if handleError(w, err, http.StatusUnsupportedMediaType, "This image cannot be edited") {
+		return
+	}
+	if err := recipe.Validate(img.Bounds()); err != nil {
+		ReturnCustomMessage(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := json.Marshal(recipe)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if handleError(w, rt.db.SetEditRecipe(photoId, string(stored)), http.StatusInternalServerError, "") {
+		return
+	}
+	rt.renditions.drop(photoId)
+
+	json.NewEncoder(w).Encode(recipe)
+}
+
+// revertPhoto discards the edits of a photo, so that the original is served again
+func (rt *_router) revertPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	if handleError(w, rt.db.SetEditRecipe(photoId, ""), http.StatusInternalServerError, "") {
+		return
+	}
+	rt.renditions.drop(photoId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Limits of the photos returned by getPhotosNear
+const (
+	defaultNearLimit = 50
+	maxNearLimit     = 200
+)
+
+// maxPlaceNameLength is the maximum length of the free-text name of a place
+const maxPlaceNameLength = 100
+
+// validLocation checks the coordinates and the name of a place
+func validLocation(location Location) error {
+	if math.IsNaN(location.Latitude) || location.Latitude < -90 || location.Latitude > 90 {
+		return errors.New("the latitude must be between -90 and 90")
+	}
+	if math.IsNaN(location.Longitude) || location.Longitude < -180 || location.Longitude > 180 {
+		return errors.New("the longitude must be between -180 and 180")
+	}
+	if len(location.Name) > maxPlaceNameLength {
+		return errors.New("the place name is too long")
+	}
+	return nil
+}
+
+// parseLocation reads the optional `lat`, `lon` and `place` query parameters of an upload. It returns nil if no
+// location is given.
+func parseLocation(r *http.Request) (*database.PhotoLocation, error) {
+	query := r.URL.Query()
+	if query.Get("lat") == "" && query.Get("lon") == "" {
+		return nil, nil
+	}
+
+	var location Location
+	var err error
+	if location.Latitude, err = strconv.ParseFloat(query.Get("lat"), 64); err != nil {
+		return nil, errors.New("invalid latitude")
+	}
+	if location.Longitude, err = strconv.ParseFloat(query.Get("lon"), 64); err != nil {
+		return nil, errors.New("invalid longitude")
+	}
+	location.Name = strings.TrimSpace(query.Get("place"))
+	if err := validLocation(location); err != nil {
+		return nil, err
+	}
+	return &database.PhotoLocation{Latitude: location.Latitude, Longitude: location.Longitude, Name: location.Name}, nil
+}
+
+// parseBoundingBox parses a `west,south,east,north` bounding box. West may be greater than east for boxes crossing the
+// antimeridian.
+func parseBoundingBox(bbox string) ([4]float64, error) {
+	var box [4]float64
+	parts := strings.Split(bbox, ",")
+	if len(parts) != 4 {
+		return box, errors.New("the bounding box must be west,south,east,north")
+	}
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(value) {
+			return box, errors.New("invalid bounding box coordinate")
+		}
+		box[i] = value
+	}
+
+	west, south, east, north := box[0], box[1], box[2], box[3]
+	if south < -90 || north > 90 || south > north {
+		return box, errors.New("the latitudes of the bounding box must be between -90 and 90, south first")
+	}
+	if west < -180 || west > 180 || east < -180 || east > 180 {
+		return box, errors.New("the longitudes of the bounding box must be between -180 and 180")
+	}
+	return box, nil
+}
+
+func (rt *_router) setPhotoLocation(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var location Location
+	if handleError(w, json.NewDecoder(r.Body).Decode(&location), http.StatusBadRequest, "Invalid location data") {
+		return
+	}
+	location.Name = strings.TrimSpace(location.Name)
+	if err := validLocation(location); err != nil {
+		ReturnCustomMessage(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	place := database.PhotoLocation{Latitude: location.Latitude, Longitude: location.Longitude, Name: location.Name}
+	if handleError(w, rt.db.SetPhotoLocation(photoId, place), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(location)
+}
+
+func (rt *_router) clearPhotoLocation(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	if handleError(w, rt.db.ClearPhotoLocation(photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPhotosNear returns the photos visible to the user inside the bounding box given in the `bbox` query parameter
+func (rt *_router) getPhotosNear(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	box, err := parseBoundingBox(r.URL.Query().Get("bbox"))
+	if err != nil {
+		ReturnCustomMessage(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultNearLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxNearLimit {
+			ReturnCustomMessage(w, "Bad Request: invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	photos, err := rt.db.GetPhotosInBox(token, box[0], box[1], box[2], box[3], limit)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// getUserMap returns the geotagged photos of a user visible to the requesting user
+func (rt *_router) getUserMap(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+
+	owner, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	banned, err := rt.db.CheckBan(owner, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if banned {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	photos, err := rt.db.GetUserMap(token, owner)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+)
+
+// Number of days covered by the daily statistics of the insights
+const (
+	defaultInsightsDays = 30
+	maxInsightsDays     = 365
+)
+
+// parseInsightsDays reads the optional `days` query parameter. If it is not valid it writes the error response and
+// returns false
+func parseInsightsDays(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return defaultInsightsDays, true
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 || days > maxInsightsDays {
+		ReturnCustomMessage(w, "Bad Request: invalid number of days", http.StatusBadRequest)
+		return 0, false
+	}
+	return days, true
+}
+
+// recordView counts a view of the photo, unless the viewer is its owner. Failing to count a view does not affect the
+// response, so errors are only logged.
+func (rt *_router) recordView(photoId int64, viewer int64) {
+	owner, err := rt.db.GetPhotoOwner(photoId)
+	if err == nil && owner != viewer {
+		err = rt.db.RecordView(photoId, viewer)
+	}
+	if err != nil {
+		rt.baseLogger.WithError(err).Warning("error recording a photo view")
+	}
+}
+
+// getPhotoInsights returns the statistics of a photo of the authenticated user
+func (rt *_router) getPhotoInsights(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	days, ok := parseInsightsDays(w, r)
+	if !ok {
+		return
+	}
+
+	insights, err := rt.db.GetPhotoInsights(photoId, days)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(insights)
+}
+
+// getProfileInsights returns the follower growth of the authenticated user
+func (rt *_router) getProfileInsights(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	days, ok := parseInsightsDays(w, r)
+	if !ok {
+		return
+	}
+
+	insights, err := rt.db.GetProfileInsights(token, days)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(insights)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+)
+
+// Limits of the notifications returned by getNotifications
+const (
+	defaultNotificationsLimit = 50
+	maxNotificationsLimit     = 200
+)
+
+func (rt *_router) getNotifications(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := defaultNotificationsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxNotificationsLimit {
+			ReturnCustomMessage(w, "Bad Request: invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	notifications, err := rt.db.GetNotifications(token, limit)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(notifications)
+}
+
+func (rt *_router) readNotifications(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if handleError(w, rt.db.MarkNotificationsRead(token), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
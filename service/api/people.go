@@ -0,0 +1,257 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// maxPeoplePerPhoto is the maximum number of people that can be tagged in a photo
+const maxPeoplePerPhoto = 20
+
+// validPosition checks that the position of a tag is inside the photo. Positions are relative to the size of the
+// photo, from 0 to 1, so that they stay valid for every rendition.
+func validPosition(position PersonTagPosition) bool {
+	inside := func(v float64) bool { return !math.IsNaN(v) && v >= 0 && v <= 1 }
+	return inside(position.X) && inside(position.Y)
+}
+
+// tagPerson tags a user in a photo of the authenticated user
+func (rt *_router) tagPerson(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+	user, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	var position PersonTagPosition
+	if handleError(w, json.NewDecoder(r.Body).Decode(&position), http.StatusBadRequest, "Invalid tag position") {
+		return
+	}
+	if !validPosition(position) {
+		ReturnCustomMessage(w, "Bad Request: the position must be between 0 and 1", http.StatusBadRequest)
+		return
+	}
+
+	// Users who banned each other cannot be tagged
+	for _, pair := range [][2]int64{{token, user}, {user, token}} {
+		banned, err := rt.db.CheckBan(pair[0], pair[1])
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+		if banned {
+			ReturnForbiddenMessage(w)
+			return
+		}
+	}
+
+	// The tagged user must be able to see the photo, which drafts, scheduled photos and restricted audiences prevent
+	canView, err := rt.db.CanViewPhoto(user, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !canView {
+		ReturnCustomMessage(w, "Forbidden: the user cannot see the photo", http.StatusForbidden)
+		return
+	}
+
+	tagged, err := rt.db.CheckPersonTag(photoId, user)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !tagged {
+		people, err := rt.db.GetPhotoPeople(photoId, token)
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+		if len(people) >= maxPeoplePerPhoto {
+			ReturnCustomMessage(w, "Forbidden: too many people tagged in the photo", http.StatusForbidden)
+			return
+		}
+	}
+
+	status, created, err := rt.db.TagPerson(photoId, token, user, position.X, position.Y)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(PersonTag{Username: username, X: position.X, Y: position.Y, Status: status})
+}
+
+// untagPerson removes a tag from a photo. The owner of the photo can remove any tag, the tagged users can only remove
+// themselves.
+func (rt *_router) untagPerson(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+	user, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	tagged, err := rt.db.CheckPersonTag(photoId, user)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !tagged {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if user != token {
+		owner, err := rt.db.CheckPhotoOwner(token, photoId)
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+		if !owner {
+			ReturnForbiddenMessage(w)
+			return
+		}
+	}
+
+	if handleError(w, rt.db.RemovePersonTag(photoId, user), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPhotoPeople returns the people tagged in a photo
+func (rt *_router) getPhotoPeople(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	if !rt.canViewPhoto(w, token, photoId) {
+		return
+	}
+
+	people, err := rt.db.GetPhotoPeople(photoId, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(people)
+}
+
+// getPendingTaggedPhotos returns the photos where the authenticated user has been tagged, waiting for approval
+func (rt *_router) getPendingTaggedPhotos(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photos, err := rt.db.GetPendingTaggedPhotos(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// approvePersonTag approves a pending tag of the authenticated user
+func (rt *_router) approvePersonTag(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	pending, err := rt.db.CheckPendingPersonTag(photoId, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !pending {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if handleError(w, rt.db.ApprovePersonTag(photoId, token), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPhotosOf returns the photos where a user has been tagged, the "photos of" tab of the profile
+func (rt *_router) getPhotosOf(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+	user, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	banned, err := rt.db.CheckBan(user, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if banned {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	photos, err := rt.db.GetPhotosOf(token, user)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+func (rt *_router) getTagApproval(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	autoApprove, err := rt.db.GetAutoApproveTags(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(TagApproval{AutoApprove: autoApprove})
+}
+
+func (rt *_router) setTagApproval(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var approval TagApproval
+	if handleError(w, json.NewDecoder(r.Body).Decode(&approval), http.StatusBadRequest, "Invalid tag approval data") {
+		return
+	}
+
+	if handleError(w, rt.db.SetAutoApproveTags(token, approval.AutoApprove), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(approval)
+}
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"WasaPhoto/service/database"
+	"WasaPhoto/service/imaging"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/julienschmidt/httprouter"
 	"io"
 	"net/http"
@@ -21,15 +25,116 @@ func handleError(w http.ResponseWriter, err error, defaultStatus int, customMess
 	return false
 }
 
+// ownedPhotoId extracts the photo id from the path and checks that the photo exists and belongs to the user. If not,
+// it writes the error response and returns false
+func (rt *_router) ownedPhotoId(w http.ResponseWriter, p httprouter.Params, token int64) (int64, bool) {
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return 0, false
+	}
+
+	exists, err := rt.db.CheckPhotoExistence(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, false
+	}
+	if !exists {
+		ReturnNotFoundError(w)
+		return 0, false
+	}
+
+	owner, err := rt.db.CheckPhotoOwner(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, false
+	}
+	if !owner {
+		ReturnForbiddenMessage(w)
+		return 0, false
+	}
+	return photoId, true
+}
+
+// maxImageBytes is the largest size of an uploaded image
+const maxImageBytes = 10 << 20
+
+// readImage reads an uploaded image from the request body, and checks that it can be decoded within the limits of
+// the imaging package. If the image is empty or too large, it writes the error response and returns false
+func readImage(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	image, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImageBytes))
+	if err != nil && len(image) >= maxImageBytes {
+		ReturnCustomMessage(w, "Request Entity Too Large: the image is larger than 10 MB", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if handleError(w, err, http.StatusBadRequest, "Invalid image data") {
+		return nil, false
+	}
+	if len(image) == 0 {
+		ReturnCustomMessage(w, "Bad Request: the image is empty", http.StatusBadRequest)
+		return nil, false
+	}
+	if errors.Is(imaging.CheckSize(image), imaging.ErrTooLarge) {
+		ReturnCustomMessage(w, "Request Entity Too Large: the image has too many pixels", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return image, true
+}
+
 func (rt *_router) uploadPhoto(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
 	w.Header().Set("Content-Type", "application/json")
 
-	photo, err := io.ReadAll(r.Body)
-	if handleError(w, err, http.StatusBadRequest, "Invalid photo data") || len(photo) == 0 {
+	photo, ok := readImage(w, r)
+	if !ok {
+		return
+	}
+
+	options := database.PhotoOptions{Visibility: r.URL.Query().Get("visibility")}
+	if options.Visibility == "" {
+		options.Visibility = database.VisibilityPublic
+	} else if !validVisibility(options.Visibility) {
+		ReturnBadRequestCustomMessage(w)
+		return
+	}
+
+	// Keep the photo as a draft, or schedule it for later
+	var err error
+	if draft := r.URL.Query().Get("draft"); draft != "" {
+		options.Draft, err = strconv.ParseBool(draft)
+		if handleError(w, err, http.StatusBadRequest, "Invalid draft flag") {
+			return
+		}
+	}
+	if publishAt := r.URL.Query().Get("publishAt"); publishAt != "" {
+		options.PublishAt, err = parsePublishTime(publishAt)
+		if handleError(w, err, http.StatusBadRequest, "Invalid publication time") {
+			return
+		}
+	}
+
+	options.Caption = r.URL.Query().Get("caption")
+	if !validCaption(options.Caption) {
+		ReturnCustomMessage(w, "Bad Request: the caption is too long", http.StatusBadRequest)
+		return
+	}
+
+	options.Location, err = parseLocation(r)
+	if err != nil {
+		ReturnCustomMessage(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if handleError(w, rt.db.PostPhoto(photo, token), http.StatusInternalServerError, "") {
+	if !rt.checkBlocklist(w, token, photo) {
+		return
+	}
+
+	// Record the animation data of animated GIFs, with a still poster for the grids
+	animation, animated, err := imaging.InspectAnimation(photo)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if animated {
+		options.Animation = &database.PhotoAnimation{FrameCount: animation.Frames, Duration: animation.Duration, Poster: animation.Poster}
+	}
+
+	if handleError(w, rt.db.PostPhoto(photo, token, options), http.StatusInternalServerError, "") {
 		return
 	}
 
@@ -61,7 +166,7 @@ func (rt *_router) deletePhoto(w http.ResponseWriter, _ *http.Request, p httprou
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (rt *_router) getPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+func (rt *_router) getPhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
 	w.Header().Set("Content-Type", "application/json")
 
 	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
@@ -69,28 +174,23 @@ func (rt *_router) getPhoto(w http.ResponseWriter, _ *http.Request, p httprouter
 		return
 	}
 
-	owner, err := rt.db.GetPhotoOwner(photoId)
-	if handleError(w, err, http.StatusInternalServerError, "") {
-		return
-	}
-
 	if !rt.db.CheckPhotoExistence(photoId) {
 		ReturnNotFoundError(w)
 		return
 	}
 
-	if rt.db.CheckBan(owner, token) {
-		ReturnForbiddenMessage(w)
+	if !rt.canViewPhoto(w, token, photoId) {
 		return
 	}
 
-	photo, err := rt.db.GetImage(photoId)
-	if handleError(w, err, http.StatusInternalServerError, "") {
+	rendition, ok := rt.parseRendition(w, r, token, photoId)
+	if !ok {
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Write(photo)
+	if rt.servePhoto(w, photoId, token, rendition) {
+		rt.recordView(photoId, token)
+	}
 }
 
 func (rt *_router) likePhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
@@ -116,8 +216,7 @@ func (rt *_router) likePhoto(w http.ResponseWriter, _ *http.Request, p httproute
 		return
 	}
 
-	if rt.db.CheckBan(pathOwner, token) {
-		ReturnForbiddenMessage(w)
+	if !rt.canViewPhoto(w, token, photoId) {
 		return
 	}
 
@@ -133,6 +232,70 @@ func (rt *_router) likePhoto(w http.ResponseWriter, _ *http.Request, p httproute
 	ReturnCreatedMessage(w)
 }
 
+// Limits of the likers returned by getLikes
+const (
+	defaultLikersLimit = 20
+	maxLikersLimit     = 100
+)
+
+// getLikes returns a page of the users who liked a photo of the user in the path
+func (rt *_router) getLikes(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	pathOwner, err := strconv.ParseInt(p.ByName("userId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid user ID") {
+		return
+	}
+
+	exists, err := rt.db.CheckPhotoExistence(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !exists {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if owner, _ := rt.db.GetPhotoOwner(photoId); owner != pathOwner {
+		ReturnBadRequestCustomMessage(w)
+		return
+	}
+
+	if !rt.canViewPhoto(w, token, photoId) {
+		return
+	}
+
+	offset := 0
+	if value := r.URL.Query().Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			ReturnCustomMessage(w, "Bad Request: invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultLikersLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxLikersLimit {
+			ReturnCustomMessage(w, "Bad Request: invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	likers, err := rt.db.GetLikers(photoId, token, offset, limit)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(likers)
+}
+
 func (rt *_router) unlikePhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -186,8 +349,7 @@ func (rt *_router) commentPhoto(w http.ResponseWriter, r *http.Request, p httpro
 		return
 	}
 
-	if owner, _ := rt.db.GetPhotoOwner(photoId); rt.db.CheckBan(owner, token) {
-		ReturnForbiddenMessage(w)
+	if !rt.canViewPhoto(w, token, photoId) {
 		return
 	}
 
@@ -196,7 +358,15 @@ func (rt *_router) commentPhoto(w http.ResponseWriter, r *http.Request, p httpro
 		return
 	}
 
-	newId, err := rt.db.CommentPhoto(token, photoId, comment.Comment)
+	if !rt.canComment(w, token, photoId) {
+		return
+	}
+
+	if comment.ParentId != nil && !rt.checkReplyParent(w, token, photoId, *comment.ParentId) {
+		return
+	}
+
+	newId, err := rt.db.CommentPhoto(token, photoId, comment.ParentId, comment.Comment)
 	if handleError(w, err, http.StatusInternalServerError, "") {
 		return
 	}
@@ -205,7 +375,15 @@ func (rt *_router) commentPhoto(w http.ResponseWriter, r *http.Request, p httpro
 	json.NewEncoder(w).Encode(CreatedCommentMessage{CommentId: newId})
 }
 
-func (rt *_router) getPhotoComments(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+// Limits of the comments returned by getPhotoComments
+const (
+	defaultCommentsLimit = 20
+	maxCommentsLimit     = 100
+)
+
+// getPhotoComments returns a page of the top-level comments of a photo. The next page is requested passing the cursor
+// returned with the page as the `cursor` query parameter.
+func (rt *_router) getPhotoComments(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
 	w.Header().Set("Content-Type", "application/json")
 
 	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
@@ -218,31 +396,83 @@ func (rt *_router) getPhotoComments(w http.ResponseWriter, _ *http.Request, p ht
 		return
 	}
 
-	if owner, _ := rt.db.GetPhotoOwner(photoId); rt.db.CheckBan(owner, token) {
-		ReturnForbiddenMessage(w)
+	if !rt.canViewPhoto(w, token, photoId) {
 		return
 	}
 
-	comments, err := rt.db.GetPhotoComments(photoId)
+	sort := r.URL.Query().Get("sort")
+	switch sort {
+	case "":
+		sort = database.CommentSortNewest
+	case database.CommentSortNewest, database.CommentSortOldest, database.CommentSortTop:
+	default:
+		ReturnCustomMessage(w, "Bad Request: invalid sort", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultCommentsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxCommentsLimit {
+			ReturnCustomMessage(w, "Bad Request: invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := rt.db.GetPhotoComments(photoId, token, sort, r.URL.Query().Get("cursor"), limit)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		ReturnCustomMessage(w, "Bad Request: invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if handleError(w, err, http.StatusInternalServerError, "") {
 		return
 	}
 
-	json.NewEncoder(w).Encode(comments)
+	// The next page is also linked in the header, with the same query and the new cursor
+	if page.Next != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", page.Next)
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", "<"+next.RequestURI()+">; rel=\"next\"")
+	}
+
+	json.NewEncoder(w).Encode(page)
 }
 
+// deleteComment deletes a comment of the authenticated user, or any comment on one of their photos
 func (rt *_router) deleteComment(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
 	w.Header().Set("Content-Type", "application/json")
 
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
 	commentId, err := strconv.ParseInt(p.ByName("commentId"), 10, 64)
 	if handleError(w, err, http.StatusBadRequest, "Invalid comment ID") {
 		return
 	}
 
-	if owner, _ := rt.db.GetCommentOwner(commentId); owner != token {
-		ReturnForbiddenMessage(w)
+	comment, err := rt.db.GetComment(commentId, token)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (comment.Photo != photoId || comment.Deleted)) {
+		ReturnNotFoundError(w)
 		return
 	}
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	if owner, _ := rt.db.GetCommentOwner(commentId); owner != token {
+		photoOwner, err := rt.db.GetPhotoOwner(photoId)
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+		if photoOwner != token {
+			ReturnForbiddenMessage(w)
+			return
+		}
+	}
 
 	if handleError(w, rt.db.DeleteComment(commentId), http.StatusInternalServerError, "") {
 		return
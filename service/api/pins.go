@@ -0,0 +1,73 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// pinPhoto pins a photo of the authenticated user at the top of their profile
+func (rt *_router) pinPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	// Drafts are not on the profile, so they cannot be pinned
+	draft, err := rt.db.CheckDraft(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if draft {
+		ReturnCustomMessage(w, "Conflict: drafts cannot be pinned", http.StatusConflict)
+		return
+	}
+
+	pinned, err := rt.db.CheckPinned(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if pinned {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	pinned, err = rt.db.PinPhoto(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !pinned {
+		ReturnCustomMessage(w, fmt.Sprintf("Conflict: at most %d photos can be pinned", database.MaxPinnedPhotos), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unpinPhoto removes a photo from the pinned photos of the authenticated user
+func (rt *_router) unpinPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	pinned, err := rt.db.CheckPinned(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !pinned {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.UnpinPhoto(photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
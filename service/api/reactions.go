@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+)
+
+// validReaction checks that the reaction is one of the configured reactions
+func (rt *_router) validReaction(reaction string) bool {
+	for _, r := range rt.reactions {
+		if r == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// reactablePhotoId extracts the photo id from the path and checks that the photo exists, belongs to the user in the
+// path, and can be seen by the authenticated user. If not, it writes the error response and returns false
+func (rt *_router) reactablePhotoId(w http.ResponseWriter, p httprouter.Params, token int64) (int64, bool) {
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return 0, false
+	}
+
+	pathOwner, err := strconv.ParseInt(p.ByName("userId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid user ID") {
+		return 0, false
+	}
+
+	exists, err := rt.db.CheckPhotoExistence(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, false
+	}
+	if !exists {
+		ReturnNotFoundError(w)
+		return 0, false
+	}
+
+	if owner, _ := rt.db.GetPhotoOwner(photoId); owner != pathOwner {
+		ReturnBadRequestCustomMessage(w)
+		return 0, false
+	}
+
+	if !rt.canViewPhoto(w, token, photoId) {
+		return 0, false
+	}
+	return photoId, true
+}
+
+// writePhotoReactions sends the reaction counts of the photo along with the reaction of the authenticated user
+func (rt *_router) writePhotoReactions(w http.ResponseWriter, token int64, photoId int64) {
+	counts, err := rt.db.GetReactionCounts(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	mine, err := rt.db.GetReaction(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(PhotoReactions{Reactions: counts, MyReaction: mine})
+}
+
+// getReactionSet returns the reactions users can choose from
+func (rt *_router) getReactionSet(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, _ int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(rt.reactions)
+}
+
+func (rt *_router) getReactions(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.reactablePhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	rt.writePhotoReactions(w, token, photoId)
+}
+
+// reactToPhoto sets the reaction of the authenticated user to a photo, replacing their previous reaction
+func (rt *_router) reactToPhoto(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.reactablePhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var reaction Reaction
+	if handleError(w, json.NewDecoder(r.Body).Decode(&reaction), http.StatusBadRequest, "Invalid reaction data") {
+		return
+	}
+	if !rt.validReaction(reaction.Reaction) {
+		ReturnCustomMessage(w, "Bad Request: unknown reaction", http.StatusBadRequest)
+		return
+	}
+
+	if handleError(w, rt.db.SetReaction(token, photoId, reaction.Reaction), http.StatusInternalServerError, "") {
+		return
+	}
+
+	rt.writePhotoReactions(w, token, photoId)
+}
+
+func (rt *_router) removeReaction(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.reactablePhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	reaction, err := rt.db.GetReaction(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if reaction == "" {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.RemoveReaction(token, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+)
+
+// renditionCacheSize is the total size in bytes of the renditions kept in memory
+const renditionCacheSize = 64 << 20
+
+// renditionKey identifies a rendition of a photo. The recipe is the stored edit recipe, empty for the original, and
+// the watermark is the watermarkSource.key of the owner, empty for no watermark.
+type renditionKey struct {
+	photo     int64
+	poster    bool
+	width     int
+	recipe    string
+	watermark string
+}
+
+// cachedRendition is an entry of the renditionCache
+type cachedRendition struct {
+	key         renditionKey
+	data        []byte
+	contentType string
+}
+
+// renditionCache keeps the most recently served renditions, so that decoding and editing the original image is not
+// repeated for every request. The least recently used renditions are evicted when the cache grows over its size.
+type renditionCache struct {
+	mu      sync.Mutex
+	size    int
+	maxSize int
+
+	// order has the most recently used renditions first
+	order   *list.List
+	entries map[renditionKey]*list.Element
+}
+
+func newRenditionCache(maxSize int) *renditionCache {
+	return &renditionCache{
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: map[renditionKey]*list.Element{},
+	}
+}
+
+// get returns the rendition with the given key, and false if it is not cached.
+func (c *renditionCache) get(key renditionKey) ([]byte, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, "", false
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*cachedRendition)
+	return entry.data, entry.contentType, true
+}
+
+// add stores a rendition, evicting the least recently used ones if needed. Renditions larger than the whole cache
+// are not stored.
+func (c *renditionCache) add(key renditionKey, data []byte, contentType string) {
+	if len(data) > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&cachedRendition{key: key, data: data, contentType: contentType})
+	c.size += len(data)
+	for c.size > c.maxSize {
+		c.remove(c.order.Back())
+	}
+}
+
+// drop removes all the renditions of a photo, to be called when its image or its edits change.
+func (c *renditionCache) drop(photoId int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.entries {
+		if key.photo == photoId {
+			c.remove(element)
+		}
+	}
+}
+
+// remove deletes an entry. The caller must hold the lock.
+func (c *renditionCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cachedRendition)
+	delete(c.entries, entry.key)
+	c.size -= len(entry.data)
+}
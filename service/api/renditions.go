@@ -0,0 +1,165 @@
+package api
+
+import (
+	"WasaPhoto/service/imaging"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+)
+
+// maxRenditionWidth is the largest width that can be requested for a rendition
+const maxRenditionWidth = 4096
+
+// rendition describes which version of a photo is served
+type rendition struct {
+	// original skips the edits of the owner
+	original bool
+
+	// poster serves the still first frame of animated photos
+	poster bool
+
+	// width scales the image down to this width, zero for the full size
+	width int
+}
+
+// parseRendition reads the rendition requested in the query string. Only the owner of the photo can ask for the
+// original. If the request is not valid, it writes the error response and returns false
+func (rt *_router) parseRendition(w http.ResponseWriter, r *http.Request, token int64, photoId int64) (rendition, bool) {
+	var result rendition
+	var err error
+
+	if value := r.URL.Query().Get("width"); value != "" {
+		result.width, err = strconv.Atoi(value)
+		if err != nil || result.width <= 0 || result.width > maxRenditionWidth {
+			ReturnCustomMessage(w, "Invalid width", http.StatusBadRequest)
+			return result, false
+		}
+	}
+
+	if value := r.URL.Query().Get("original"); value != "" {
+		result.original, err = strconv.ParseBool(value)
+		if handleError(w, err, http.StatusBadRequest, "Invalid original flag") {
+			return result, false
+		}
+	}
+	if result.original {
+		owner, err := rt.db.CheckPhotoOwner(token, photoId)
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return result, false
+		}
+		if !owner {
+			ReturnForbiddenMessage(w)
+			return result, false
+		}
+	}
+	return result, true
+}
+
+// renderPhoto returns the image served to the viewer for a photo, derived from the original and the edits of the
+// owner, and its content type. Viewers other than the owner get the watermark of the owner, if any. The derived
+// renditions are cached, see renditionCache
+func (rt *_router) renderPhoto(photoId int64, viewer int64, rendition rendition) ([]byte, string, error) {
+	owner, err := rt.db.GetPhotoOwner(photoId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := renditionKey{photo: photoId, poster: rendition.poster, width: rendition.width}
+	if !rendition.original {
+		key.recipe, err = rt.db.GetEditRecipe(photoId)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	var watermark *watermarkSource
+	if viewer != owner {
+		watermark, err = rt.loadWatermark(owner)
+		if err != nil {
+			return nil, "", err
+		}
+		key.watermark = watermark.key()
+	}
+
+	if data, contentType, ok := rt.renditions.get(key); ok {
+		return data, contentType, nil
+	}
+
+	// Animated photos have a still poster, still photos are their own poster
+	var image []byte
+	if rendition.poster {
+		image, err = rt.db.GetPoster(photoId)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if image == nil {
+		image, err = rt.db.GetImage(photoId)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	options := imaging.RenderOptions{Width: rendition.width}
+	if key.recipe != "" {
+		options.Recipe = &imaging.Recipe{}
+		if err := json.Unmarshal([]byte(key.recipe), options.Recipe); err != nil {
+			return nil, "", err
+		}
+	}
+	options.Watermark, err = watermark.build()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The images served as stored are not worth caching
+	data, contentType, err := imaging.Render(image, options)
+	if err == nil && (options.Recipe != nil || options.Watermark != nil || options.Width != 0) {
+		rt.renditions.add(key, data, contentType)
+	}
+	return data, contentType, err
+}
+
+// servePhoto writes the rendition of the photo as the response, and returns false if it failed
+func (rt *_router) servePhoto(w http.ResponseWriter, photoId int64, viewer int64, rendition rendition) bool {
+	photo, contentType, err := rt.renderPhoto(photoId, viewer, rendition)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return false
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(photo)
+	return true
+}
+
+// getPhotoPoster returns a still image of the photo, the first frame for animated photos
+func (rt *_router) getPhotoPoster(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	exists, err := rt.db.CheckPhotoExistence(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !exists {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if !rt.canViewPhoto(w, token, photoId) {
+		return
+	}
+
+	rendition, ok := rt.parseRendition(w, r, token, photoId)
+	if !ok {
+		return
+	}
+	rendition.poster = true
+
+	rt.servePhoto(w, photoId, token, rendition)
+}
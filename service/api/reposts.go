@@ -0,0 +1,75 @@
+package api
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+)
+
+// repostPhoto shares a photo of another user with the followers of the authenticated user
+func (rt *_router) repostPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.visiblePhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	owner, err := rt.db.GetPhotoOwner(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if owner == token {
+		ReturnCustomMessage(w, "Bad Request: you cannot repost your own photo", http.StatusBadRequest)
+		return
+	}
+
+	// The photos of the users banned by the authenticated user cannot be reposted either
+	banned, err := rt.db.CheckBan(token, owner)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if banned {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	reposted, err := rt.db.CheckRepost(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if reposted {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.Repost(token, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	ReturnCreatedMessage(w)
+}
+
+func (rt *_router) removeRepost(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	reposted, err := rt.db.CheckRepost(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !reposted {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.RemoveRepost(token, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"WasaPhoto/service/imaging"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// editPost changes the caption and the location of a photo of the authenticated user. The previous version is kept in
+// the history of the photo
+func (rt *_router) editPost(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var changes PhotoChanges
+	if handleError(w, json.NewDecoder(r.Body).Decode(&changes), http.StatusBadRequest, "Invalid edit data") {
+		return
+	}
+	if changes.Caption == nil && changes.Location == nil && !changes.RemoveLocation {
+		ReturnCustomMessage(w, "Bad Request: nothing to change", http.StatusBadRequest)
+		return
+	}
+
+	edit := database.PhotoEdit{Caption: changes.Caption, RemoveLocation: changes.RemoveLocation}
+	if changes.Caption != nil && !validCaption(*changes.Caption) {
+		ReturnCustomMessage(w, "Bad Request: the caption is too long", http.StatusBadRequest)
+		return
+	}
+	if changes.Location != nil {
+		changes.Location.Name = strings.TrimSpace(changes.Location.Name)
+		if err := validLocation(*changes.Location); err != nil {
+			ReturnCustomMessage(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		edit.Location = &database.PhotoLocation{Latitude: changes.Location.Latitude, Longitude: changes.Location.Longitude, Name: changes.Location.Name}
+		changes.RemoveLocation = false
+	}
+
+	if handleError(w, rt.db.EditPhoto(photoId, edit), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(changes)
+}
+
+// replacePhotoImage replaces the image of a photo of the authenticated user, keeping its likes and comments. The
+// previous image is kept in the history of the photo
+func (rt *_router) replacePhotoImage(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	image, ok := readImage(w, r)
+	if !ok {
+		return
+	}
+
+	if !rt.checkBlocklist(w, token, image) {
+		return
+	}
+
+	var animation *database.PhotoAnimation
+	inspected, animated, err := imaging.InspectAnimation(image)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if animated {
+		animation = &database.PhotoAnimation{FrameCount: inspected.Frames, Duration: inspected.Duration, Poster: inspected.Poster}
+	}
+
+	if handleError(w, rt.db.ReplaceImage(photoId, image, animation), http.StatusInternalServerError, "") {
+		return
+	}
+	rt.renditions.drop(photoId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPhotoHistory returns the previous versions of a photo
+func (rt *_router) getPhotoHistory(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.visiblePhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	revisions, err := rt.db.GetPhotoHistory(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// getRevisionImage returns the image of a previous version of a photo, with the watermark of the owner for the other
+// users
+func (rt *_router) getRevisionImage(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.visiblePhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	revision, err := strconv.ParseInt(p.ByName("revision"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid revision") {
+		return
+	}
+
+	image, err := rt.db.GetRevisionImage(photoId, revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		ReturnNotFoundError(w)
+		return
+	}
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	owner, err := rt.db.GetPhotoOwner(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	var options imaging.RenderOptions
+	if token != owner {
+		watermark, err := rt.loadWatermark(owner)
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+		options.Watermark, err = watermark.build()
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+	}
+
+	rendered, contentType, err := imaging.Render(image, options)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(rendered)
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCollectionNameLength is the maximum number of characters of the name of a collection
+const maxCollectionNameLength = 50
+
+// validCollectionName checks the name of a collection, trimming the spaces around it
+func validCollectionName(name *CollectionName) bool {
+	name.Name = strings.TrimSpace(name.Name)
+	return name.Name != "" && utf8.RuneCountInString(name.Name) <= maxCollectionNameLength
+}
+
+// ownedCollectionId extracts the collection id from the path and checks that the collection belongs to the user. If
+// not, it writes the error response and returns false
+func (rt *_router) ownedCollectionId(w http.ResponseWriter, p httprouter.Params, token int64) (int64, bool) {
+	collectionId, err := strconv.ParseInt(p.ByName("collectionId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid collection ID") {
+		return 0, false
+	}
+
+	// Collections are private, so the ones of other users are not found either
+	owned, err := rt.db.CheckCollectionOwner(token, collectionId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, false
+	}
+	if !owned {
+		ReturnNotFoundError(w)
+		return 0, false
+	}
+	return collectionId, true
+}
+
+// visiblePhotoId extracts the photo id from the path and checks that the user can see the photo. If not, it writes the
+// error response and returns false
+func (rt *_router) visiblePhotoId(w http.ResponseWriter, p httprouter.Params, token int64) (int64, bool) {
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return 0, false
+	}
+
+	exists, err := rt.db.CheckPhotoExistence(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, false
+	}
+	if !exists {
+		ReturnNotFoundError(w)
+		return 0, false
+	}
+
+	if !rt.canViewPhoto(w, token, photoId) {
+		return 0, false
+	}
+	return photoId, true
+}
+
+func (rt *_router) savePhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.visiblePhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	saved, err := rt.db.CheckSaved(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if saved {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.SavePhoto(token, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unsavePhoto removes a photo from the saved photos of the authenticated user and from all their collections
+func (rt *_router) unsavePhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	saved, err := rt.db.CheckSaved(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !saved {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.UnsavePhoto(token, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rt *_router) getSavedPhotos(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photos, err := rt.db.GetSavedPhotos(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+func (rt *_router) getCollections(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collections, err := rt.db.GetCollections(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(collections)
+}
+
+func (rt *_router) createCollection(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var name CollectionName
+	if handleError(w, json.NewDecoder(r.Body).Decode(&name), http.StatusBadRequest, "Invalid collection data") {
+		return
+	}
+	if !validCollectionName(&name) {
+		ReturnCustomMessage(w, "Bad Request: the name must be between 1 and 50 characters", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := rt.db.CheckCollectionName(token, name.Name)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if exists {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	collectionId, err := rt.db.CreateCollection(token, name.Name)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreatedCollectionMessage{CollectionId: collectionId})
+}
+
+func (rt *_router) renameCollection(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collectionId, ok := rt.ownedCollectionId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var name CollectionName
+	if handleError(w, json.NewDecoder(r.Body).Decode(&name), http.StatusBadRequest, "Invalid collection data") {
+		return
+	}
+	if !validCollectionName(&name) {
+		ReturnCustomMessage(w, "Bad Request: the name must be between 1 and 50 characters", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := rt.db.CheckCollectionName(token, name.Name)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if exists {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.RenameCollection(collectionId, name.Name), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(name)
+}
+
+// deleteCollection deletes a collection of the authenticated user, its photos stay saved
+func (rt *_router) deleteCollection(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collectionId, ok := rt.ownedCollectionId(w, p, token)
+	if !ok {
+		return
+	}
+
+	if handleError(w, rt.db.DeleteCollection(collectionId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rt *_router) getCollectionPhotos(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collectionId, ok := rt.ownedCollectionId(w, p, token)
+	if !ok {
+		return
+	}
+
+	photos, err := rt.db.GetCollectionPhotos(token, collectionId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// addCollectionPhoto adds a photo to a collection of the authenticated user, saving it if needed
+func (rt *_router) addCollectionPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collectionId, ok := rt.ownedCollectionId(w, p, token)
+	if !ok {
+		return
+	}
+
+	photoId, ok := rt.visiblePhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	exists, err := rt.db.CheckCollectionPhoto(collectionId, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if exists {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.AddCollectionPhoto(token, collectionId, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeCollectionPhoto removes a photo from a collection of the authenticated user, the photo stays saved
+func (rt *_router) removeCollectionPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	collectionId, ok := rt.ownedCollectionId(w, p, token)
+	if !ok {
+		return
+	}
+
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return
+	}
+
+	exists, err := rt.db.CheckCollectionPhoto(collectionId, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !exists {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if handleError(w, rt.db.RemoveCollectionPhoto(collectionId, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"WasaPhoto/service/globaltime"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ownedStoryId extracts the story id from the path and checks that the story belongs to the user. If not, it writes
+// the error response and returns false
+func (rt *_router) ownedStoryId(w http.ResponseWriter, p httprouter.Params, token int64) (int64, bool) {
+	storyId, err := strconv.ParseInt(p.ByName("storyId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid story ID") {
+		return 0, false
+	}
+
+	// The stories of other users are not found here, they are reached through their owner
+	owned, err := rt.db.CheckStoryOwner(token, storyId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, false
+	}
+	if !owned {
+		ReturnNotFoundError(w)
+		return 0, false
+	}
+	return storyId, true
+}
+
+// uploadStory posts a story that disappears after a day
+func (rt *_router) uploadStory(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	image, ok := readImage(w, r)
+	if !ok {
+		return
+	}
+
+	// Stories are subject to the same blocklist as the photos
+	if !rt.checkBlocklist(w, token, image) {
+		return
+	}
+
+	storyId, err := rt.db.PostStory(token, image)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreatedStoryMessage{StoryId: storyId})
+}
+
+func (rt *_router) getMyStories(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stories, err := rt.db.GetMyStories(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(stories)
+}
+
+// deleteStory deletes an active or archived story of the authenticated user
+func (rt *_router) deleteStory(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	storyId, ok := rt.ownedStoryId(w, p, token)
+	if !ok {
+		return
+	}
+
+	if handleError(w, rt.db.DeleteStory(storyId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getStoryViewers returns who has seen a story of the authenticated user
+func (rt *_router) getStoryViewers(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	storyId, ok := rt.ownedStoryId(w, p, token)
+	if !ok {
+		return
+	}
+
+	viewers, err := rt.db.GetStoryViewers(storyId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(viewers)
+}
+
+// getStoryTray returns the followed users who have active stories
+func (rt *_router) getStoryTray(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tray, err := rt.db.GetStoryTray(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(tray)
+}
+
+func (rt *_router) getStoriesOf(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+	user, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	banned, err := rt.db.CheckBan(user, token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if banned {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	stories, err := rt.db.GetStoriesOf(token, user)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(stories)
+}
+
+// getStory returns the image of a story and adds the authenticated user to its viewers. Expired stories can only be
+// seen by their owner, from the archive.
+func (rt *_router) getStory(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+	user, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	storyId, err := strconv.ParseInt(p.ByName("storyId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid story ID") {
+		return
+	}
+
+	owned, err := rt.db.CheckStoryOwner(user, storyId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	visible, err := rt.db.CanViewStory(token, storyId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !owned || !visible {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	image, err := rt.db.GetStoryImage(storyId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	// Failing to record the view does not affect the response, so errors are only logged
+	if err := rt.db.RecordStoryView(storyId, token); err != nil {
+		rt.baseLogger.WithError(err).Warning("error recording a story view")
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(image))
+	w.Write(image)
+}
+
+func (rt *_router) getStoryArchive(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stories, err := rt.db.GetStoryArchive(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(stories)
+}
+
+func (rt *_router) getStoryArchiveSetting(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	archive, err := rt.db.GetArchiveStories(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(StoryArchive{Archive: archive})
+}
+
+func (rt *_router) setStoryArchiveSetting(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var setting StoryArchive
+	if handleError(w, json.NewDecoder(r.Body).Decode(&setting), http.StatusBadRequest, "Invalid story archive data") {
+		return
+	}
+
+	if handleError(w, rt.db.SetArchiveStories(token, setting.Archive), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(setting)
+}
+
+// expireStories archives or deletes, every interval, the stories expired according to globaltime.Now. It runs until
+// the router is closed.
+func (rt *_router) expireStories(interval time.Duration) {
+	defer rt.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rt.done:
+			return
+		case <-ticker.C:
+			expired, err := rt.db.ExpireStories(globaltime.Now())
+			if err != nil {
+				rt.baseLogger.WithError(err).Warning("error expiring the stories")
+			} else if expired > 0 {
+				rt.baseLogger.Infof("expired %d stories", expired)
+			}
+		}
+	}
+}
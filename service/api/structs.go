@@ -24,12 +24,18 @@ type Message struct {
 	Message string `json:"message"`
 }
 
+type ErrorCodeMessage struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 type CreatedCommentMessage struct {
 	CommentId int64 `json:"comment_id"`
 }
 
 type Comment struct {
-	Comment string `json:"comment"`
+	Comment  string `json:"comment"`
+	ParentId *int64 `json:"parent_id,omitempty"`
 }
 
 type FullDataComment struct {
@@ -38,15 +44,287 @@ type FullDataComment struct {
 	CreatedAt string `json:"created_at"`
 	Owner     string `json:"owner"`
 	Photo     int64  `json:"photo"`
+
+	// ParentId is the comment this one replies to, it is nil for the top-level comments
+	ParentId        *int64 `json:"parent_id,omitempty"`
+	NumberOfReplies int64  `json:"number_of_replies"`
+
+	// Deleted marks the tombstone of a deleted comment that still has replies, its content and owner are empty
+	Deleted bool `json:"deleted"`
+
+	// EditedAt and History are only set for the comments edited by their author, History is the path of their
+	// previous versions
+	EditedAt string `json:"editedAt,omitempty"`
+	History  string `json:"history,omitempty"`
+
+	NumberOfLikes int64 `json:"number_of_likes"`
+	IsLiked       bool  `json:"is_liked"`
+
+	Mentions []Mention `json:"mentions,omitempty"`
+
+	// Hidden marks the comments hidden by the keyword filter of the photo owner, which only they and the author see
+	Hidden bool `json:"hidden"`
+}
+
+// Mention is a `@username` in a text, as a range of characters including the `@`. Username is the current name of the
+// mentioned user, which differs from the text if the user was renamed.
+type Mention struct {
+	Start    int    `json:"start"`
+	Length   int    `json:"length"`
+	UserId   int64  `json:"userId"`
+	Username string `json:"username"`
+}
+
+type CommentsPage struct {
+	Comments []FullDataComment `json:"comments"`
+
+	// Next is the cursor of the next page, empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+type CommentSettings struct {
+	Mode string `json:"mode"`
+}
+
+type CommentFilter struct {
+	Keywords []string `json:"keywords"`
+}
+
+type CommentRevision struct {
+	Revision  int64  `json:"revision"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"createdAt"`
 }
 
 type Photo struct {
-	Id               int64  `json:"id"`
-	Owner            int64  `json:"owner"`
-	OwnerUsername    string `json:"ownerUsername"`
-	CreatedAt        string `json:"createdAt"`
-	NumberOfLikes    int64  `json:"numberOfLikes"`
-	NumberOfComments int64  `json:"numberOfComments"`
-	IsLiked          bool   `json:"isLiked"`
+	Id               int64            `json:"id"`
+	Owner            int64            `json:"owner"`
+	OwnerUsername    string           `json:"ownerUsername"`
+	CreatedAt        string           `json:"createdAt"`
+	NumberOfLikes    int64            `json:"numberOfLikes"`
+	NumberOfComments int64            `json:"numberOfComments"`
+	IsLiked          bool             `json:"isLiked"`
+	Visibility       string           `json:"visibility"`
+	DeletedAt        string           `json:"deletedAt,omitempty"`
+	IsDraft          bool             `json:"isDraft,omitempty"`
+	PublishAt        string           `json:"publishAt,omitempty"`
+	IsAnimated       bool             `json:"isAnimated"`
+	FrameCount       int64            `json:"frameCount"`
+	DurationMs       int64            `json:"durationMs"`
+	Location         *Location        `json:"location,omitempty"`
+	Caption          string           `json:"caption"`
+	Mentions         []Mention        `json:"mentions,omitempty"`
+	CommentsMode     string           `json:"commentsMode"`
+	Pinned           bool             `json:"pinned"`
+	IsSaved          bool             `json:"isSaved"`
+	Reactions        map[string]int64 `json:"reactions"`
+	MyReaction       string           `json:"myReaction,omitempty"`
+	RepostedBy       string           `json:"repostedBy,omitempty"`
+	RepostedAt       string           `json:"repostedAt,omitempty"`
+	Edited           bool             `json:"edited"`
+	EditedAt         string           `json:"editedAt,omitempty"`
+}
+
+type PhotoChanges struct {
+	Caption        *string   `json:"caption,omitempty"`
+	Location       *Location `json:"location,omitempty"`
+	RemoveLocation bool      `json:"removeLocation,omitempty"`
+}
+
+type PhotoRevision struct {
+	Revision      int64     `json:"revision"`
+	Caption       string    `json:"caption"`
+	Location      *Location `json:"location,omitempty"`
+	ImageReplaced bool      `json:"imageReplaced"`
+	CreatedAt     string    `json:"createdAt"`
+}
+
+type Reaction struct {
+	Reaction string `json:"reaction"`
+}
+
+type PhotoReactions struct {
+	Reactions  map[string]int64 `json:"reactions"`
+	MyReaction string           `json:"myReaction,omitempty"`
+}
+
+type Liker struct {
+	Username   string `json:"username"`
+	LikedAt    string `json:"likedAt"`
+	IsFollowed bool   `json:"isFollowed"`
+}
+
+type Caption struct {
+	Caption string `json:"caption"`
+}
+
+type Tag struct {
+	Name           string `json:"name"`
+	NumberOfPhotos int64  `json:"numberOfPhotos"`
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name,omitempty"`
+}
+
+type PersonTag struct {
+	Username  string  `json:"username"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Status    string  `json:"status"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+type PersonTagPosition struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type TagApproval struct {
+	AutoApprove bool `json:"autoApprove"`
+}
+
+type Notification struct {
+	Id        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	Actor     string `json:"actor"`
+	Photo     int64  `json:"photo"`
+	CreatedAt string `json:"createdAt"`
+	Read      bool   `json:"read"`
+}
+
+type PhotoInsights struct {
+	Views         int64             `json:"views"`
+	UniqueViewers int64             `json:"uniqueViewers"`
+	Likes         int64             `json:"likes"`
+	Comments      int64             `json:"comments"`
+	Daily         []DailyPhotoStats `json:"daily"`
+}
+
+type DailyPhotoStats struct {
+	Date     string `json:"date"`
+	Views    int64  `json:"views"`
+	Likes    int64  `json:"likes"`
+	Comments int64  `json:"comments"`
+}
+
+type ProfileInsights struct {
+	Followers int64                `json:"followers"`
+	Daily     []DailyFollowerStats `json:"daily"`
+}
+
+type DailyFollowerStats struct {
+	Date      string `json:"date"`
+	Gained    int64  `json:"gained"`
+	Lost      int64  `json:"lost"`
+	Followers int64  `json:"followers"`
+}
+
+type Album struct {
+	Id             int64   `json:"id"`
+	Owner          int64   `json:"owner"`
+	OwnerUsername  string  `json:"ownerUsername"`
+	Title          string  `json:"title"`
+	Description    string  `json:"description"`
+	Visibility     string  `json:"visibility"`
+	CoverPhoto     int64   `json:"coverPhoto,omitempty"`
+	NumberOfPhotos int64   `json:"numberOfPhotos"`
+	Role           string  `json:"role,omitempty"`
+	CreatedAt      string  `json:"createdAt"`
+	Photos         []Photo `json:"photos,omitempty"`
 }
 
+type AlbumSettings struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+	CoverPhoto  int64  `json:"coverPhoto,omitempty"`
+}
+
+type AlbumMember struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type AlbumRole struct {
+	Role string `json:"role"`
+}
+
+type AlbumOrder struct {
+	Photos []int64 `json:"photos"`
+}
+
+type CreatedAlbumMessage struct {
+	AlbumId int64 `json:"albumId"`
+}
+
+type Collection struct {
+	Id             int64  `json:"id"`
+	Name           string `json:"name"`
+	NumberOfPhotos int64  `json:"numberOfPhotos"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+type CollectionName struct {
+	Name string `json:"name"`
+}
+
+type CreatedCollectionMessage struct {
+	CollectionId int64 `json:"collectionId"`
+}
+
+type Story struct {
+	Id            int64  `json:"id"`
+	Owner         int64  `json:"owner"`
+	OwnerUsername string `json:"ownerUsername"`
+	CreatedAt     string `json:"createdAt"`
+	ExpiresAt     string `json:"expiresAt"`
+	Seen          bool   `json:"seen"`
+	NumberOfViews int64  `json:"numberOfViews,omitempty"`
+}
+
+type StoryTrayEntry struct {
+	Owner           int64  `json:"owner"`
+	Username        string `json:"username"`
+	UpdatedAt       string `json:"updatedAt"`
+	HasUnseen       bool   `json:"hasUnseen"`
+	NumberOfStories int64  `json:"numberOfStories"`
+}
+
+type StoryViewer struct {
+	Username string `json:"username"`
+	ViewedAt string `json:"viewedAt"`
+}
+
+type StoryArchive struct {
+	Archive bool `json:"archive"`
+}
+
+type CreatedStoryMessage struct {
+	StoryId int64 `json:"storyId"`
+}
+
+type Watermark struct {
+	Kind     string  `json:"kind"`
+	Position string  `json:"position"`
+	Opacity  float64 `json:"opacity"`
+}
+
+type PublishSchedule struct {
+	PublishAt string `json:"publishAt"`
+}
+
+type Visibility struct {
+	Visibility string `json:"visibility"`
+}
+
+type BlockedUpload struct {
+	Id            int64  `json:"id"`
+	Owner         int64  `json:"owner"`
+	OwnerUsername string `json:"ownerUsername"`
+	MatchType     string `json:"matchType"`
+	Hash          string `json:"hash"`
+	CreatedAt     string `json:"createdAt"`
+}
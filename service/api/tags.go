@@ -0,0 +1,110 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxCaptionLength is the maximum number of characters of a caption
+const maxCaptionLength = 2200
+
+// Limits of the photos returned by getTagPhotos
+const (
+	defaultTagPhotosLimit = 30
+	maxTagPhotosLimit     = 100
+)
+
+// validCaption checks the length of a caption
+func validCaption(caption string) bool {
+	return utf8.ValidString(caption) && utf8.RuneCountInString(caption) <= maxCaptionLength
+}
+
+func (rt *_router) setCaption(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var caption Caption
+	if handleError(w, json.NewDecoder(r.Body).Decode(&caption), http.StatusBadRequest, "Invalid caption data") {
+		return
+	}
+	if !validCaption(caption.Caption) {
+		ReturnCustomMessage(w, "Bad Request: the caption is too long", http.StatusBadRequest)
+		return
+	}
+
+	if handleError(w, rt.db.SetCaption(photoId, caption.Caption), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(caption)
+}
+
+// getTag returns the tag with the number of its photos visible to the user
+func (rt *_router) getTag(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := database.NormalizeHashtag(p.ByName("tag"))
+	if name == "" {
+		ReturnCustomMessage(w, "Bad Request: invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := rt.db.GetTag(token, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		ReturnNotFoundError(w)
+		return
+	}
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(tag)
+}
+
+// getTagPhotos returns a page of the photos with the tag visible to the user. The next page is requested passing the
+// id of the last photo received as the `before` query parameter.
+func (rt *_router) getTagPhotos(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := database.NormalizeHashtag(p.ByName("tag"))
+	if name == "" {
+		ReturnCustomMessage(w, "Bad Request: invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	var before int64
+	var err error
+	if value := r.URL.Query().Get("before"); value != "" {
+		before, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || before <= 0 {
+			ReturnCustomMessage(w, "Bad Request: invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultTagPhotosLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxTagPhotosLimit {
+			ReturnCustomMessage(w, "Bad Request: invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	photos, err := rt.db.GetTagPhotos(token, name, before, limit)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"WasaPhoto/service/globaltime"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (rt *_router) getTrash(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photos, err := rt.db.GetTrash(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(photos)
+}
+
+// trashedPhotoId extracts the photo id from the path and checks that the photo is in the trash of the user. If not, it
+// writes the error response and returns false
+func (rt *_router) trashedPhotoId(w http.ResponseWriter, p httprouter.Params, token int64) (int64, bool) {
+	photoId, err := strconv.ParseInt(p.ByName("photoId"), 10, 64)
+	if handleError(w, err, http.StatusBadRequest, "Invalid photo ID") {
+		return 0, false
+	}
+
+	trashed, err := rt.db.CheckTrashedPhoto(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return 0, false
+	}
+	if !trashed {
+		ReturnNotFoundError(w)
+		return 0, false
+	}
+	return photoId, true
+}
+
+func (rt *_router) getTrashedPhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.trashedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	photo, err := rt.db.GetImage(photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(photo)
+}
+
+func (rt *_router) restorePhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.trashedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	if handleError(w, rt.db.RestorePhoto(token, photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rt *_router) purgePhoto(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.trashedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	if handleError(w, rt.db.PurgePhoto(photoId), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeTrash permanently deletes, every interval, the photos that have been in the trash for longer than retention.
+// It runs until the router is closed.
+func (rt *_router) purgeTrash(retention time.Duration, interval time.Duration) {
+	defer rt.workers.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rt.done:
+			return
+		case <-ticker.C:
+			purged, err := rt.db.PurgeTrash(globaltime.Now().Add(-retention))
+			if err != nil {
+				rt.baseLogger.WithError(err).Warning("error purging the trash")
+			} else if purged > 0 {
+				rt.baseLogger.Infof("purged %d photos from the trash", purged)
+			}
+		}
+	}
+}
@@ -66,6 +66,20 @@ func ReturnConflictMessage(w http.ResponseWriter) {
 	ReturnInternalServerError(w, err)
 }
 
+// ReturnCustomMessage sends a response with a custom message and status code
+func ReturnCustomMessage(w http.ResponseWriter, message string, statusCode int) {
+	err := sendJSONResponse(w, statusCode, message)
+	ReturnInternalServerError(w, err)
+}
+
+// ReturnImageBlockedMessage sends a 422 Unprocessable Entity response for an image matching the blocklist
+func ReturnImageBlockedMessage(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	res := structs.ErrorCodeMessage{Code: "image_blocked", Message: "This image is prohibited"}
+	err := json.NewEncoder(w).Encode(res)
+	ReturnInternalServerError(w, err)
+}
+
 // Token Functions
 
 // ExtractToken extracts and parses a Bearer token from the request header
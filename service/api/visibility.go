@@ -0,0 +1,120 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// validVisibility checks if the string is one of the photo visibility levels
+func validVisibility(visibility string) bool {
+	switch visibility {
+	case database.VisibilityPublic, database.VisibilityFollowers, database.VisibilityCloseFriends, database.VisibilityOnlyMe:
+		return true
+	}
+	return false
+}
+
+// canViewPhoto checks if the user is in the audience of the photo. If not, it writes the error response and returns
+// false
+func (rt *_router) canViewPhoto(w http.ResponseWriter, token int64, photoId int64) bool {
+	visible, err := rt.db.CanViewPhoto(token, photoId)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return false
+	}
+	if !visible {
+		ReturnForbiddenMessage(w)
+		return false
+	}
+	return true
+}
+
+func (rt *_router) setPhotoVisibility(w http.ResponseWriter, r *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	photoId, ok := rt.ownedPhotoId(w, p, token)
+	if !ok {
+		return
+	}
+
+	var visibility Visibility
+	if handleError(w, json.NewDecoder(r.Body).Decode(&visibility), http.StatusBadRequest, "Invalid visibility data") {
+		return
+	}
+	if !validVisibility(visibility.Visibility) {
+		ReturnBadRequestCustomMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.SetPhotoVisibility(photoId, visibility.Visibility), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(visibility)
+}
+
+func (rt *_router) addCloseFriend(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+
+	friend, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if friend == token {
+		ReturnForbiddenMessage(w)
+		return
+	}
+
+	check, err := rt.db.CheckCloseFriend(token, friend)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if check {
+		ReturnConflictMessage(w)
+		return
+	}
+
+	if handleError(w, rt.db.AddCloseFriend(token, username), http.StatusInternalServerError, "") {
+		return
+	}
+
+	ReturnCreatedMessage(w)
+}
+
+func (rt *_router) removeCloseFriend(w http.ResponseWriter, _ *http.Request, p httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	username := p.ByName("username")
+	if !CheckUsernameRegex(w, username) {
+		return
+	}
+
+	friend, err := rt.db.GetUserTokenOnly(username)
+	if err != nil {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	check, err := rt.db.CheckCloseFriend(token, friend)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if !check {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	if handleError(w, rt.db.RemoveCloseFriend(token, username), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"WasaPhoto/service/database"
+	"WasaPhoto/service/imaging"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// watermarkSource is the watermark configured by a user, as stored. It is only decoded by build, so that the cached
+// renditions can be looked up without decoding the watermark image.
+type watermarkSource struct {
+	settings database.Watermark
+
+	// text is the username of the owner, for the username watermarks
+	text string
+
+	// image is the encoded image, for the image watermarks
+	image []byte
+}
+
+// loadWatermark returns the watermark configured by a user, nil if the user has none
+func (rt *_router) loadWatermark(owner int64) (*watermarkSource, error) {
+	settings, err := rt.db.GetWatermark(owner)
+	if err != nil || settings.Kind == "" {
+		return nil, err
+	}
+
+	source := watermarkSource{settings: settings}
+	switch settings.Kind {
+	case database.WatermarkImage:
+		source.image, err = rt.db.GetWatermarkImage(owner)
+		if err != nil {
+			return nil, err
+		}
+		if source.image == nil {
+			return nil, errors.New("missing watermark image")
+		}
+	default:
+		source.text, err = rt.db.GetUsername(owner)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &source, nil
+}
+
+// key identifies the watermark in the keys of the cached renditions, empty for no watermark. The image is identified
+// by its hash, so that replacing it changes the key.
+func (source *watermarkSource) key() string {
+	if source == nil {
+		return ""
+	}
+	hash := sha256.Sum256(source.image)
+	return fmt.Sprintf("%s|%s|%g|%s|%x", source.settings.Kind, source.settings.Position, source.settings.Opacity, source.text, hash)
+}
+
+// build decodes the watermark, nil for no watermark
+func (source *watermarkSource) build() (*imaging.Watermark, error) {
+	if source == nil {
+		return nil, nil
+	}
+
+	watermark := imaging.Watermark{Text: source.text, Position: source.settings.Position, Opacity: source.settings.Opacity}
+	if source.image != nil {
+		var err error
+		watermark.Image, _, err = imaging.Decode(source.image)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &watermark, nil
+}
+
+func (rt *_router) getWatermark(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	watermark, err := rt.db.GetWatermark(token)
+	if handleError(w, err, http.StatusInternalServerError, "") {
+		return
+	}
+	if watermark.Kind == "" {
+		ReturnNotFoundError(w)
+		return
+	}
+
+	json.NewEncoder(w).Encode(watermark)
+}
+
+func (rt *_router) setWatermark(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var watermark Watermark
+	if handleError(w, json.NewDecoder(r.Body).Decode(&watermark), http.StatusBadRequest, "Invalid watermark data") {
+		return
+	}
+
+	if watermark.Kind != database.WatermarkUsername && watermark.Kind != database.WatermarkImage ||
+		!imaging.ValidPosition(watermark.Position) || watermark.Opacity < 0 || watermark.Opacity > 1 {
+		ReturnBadRequestCustomMessage(w)
+		return
+	}
+
+	// An image watermark needs the image to be uploaded first
+	if watermark.Kind == database.WatermarkImage {
+		stored, err := rt.db.GetWatermarkImage(token)
+		if handleError(w, err, http.StatusInternalServerError, "") {
+			return
+		}
+		if stored == nil {
+			ReturnCustomMessage(w, "Upload the watermark image first", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if handleError(w, rt.db.SetWatermark(token, watermark.Kind, watermark.Position, watermark.Opacity), http.StatusInternalServerError, "") {
+		return
+	}
+
+	json.NewEncoder(w).Encode(watermark)
+}
+
+func (rt *_router) deleteWatermark(w http.ResponseWriter, _ *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if handleError(w, rt.db.DeleteWatermark(token), http.StatusInternalServerError, "") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rt *_router) setWatermarkImage(w http.ResponseWriter, r *http.Request, _ httprouter.Params, token int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	image, ok := readImage(w, r)
+	if !ok {
+		return
+	}
+
+	if _, format, err := imaging.Decode(image); err != nil || format != "png" {
+		ReturnCustomMessage(w, "The watermark must be a PNG image", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if handleError(w, rt.db.SetWatermarkImage(token, image), http.StatusInternalServerError, "") {
+		return
+	}
+
+	ReturnCreatedMessage(w)
+}
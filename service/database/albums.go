@@ -0,0 +1,301 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+)
+
+// Album visibility levels
+const (
+	AlbumPublic  = "public"
+	AlbumMembers = "members"
+)
+
+// Roles of the users in an album
+const (
+	AlbumOwner       = "owner"
+	AlbumContributor = "contributor"
+	AlbumViewer      = "viewer"
+)
+
+// albumColumns are the columns of `album` read by queryAlbums, with the owner joined as `u`. The cover and the number
+// of photos only consider the photos listed to the viewer, and the role is the one of the viewer. The placeholders are
+// bound to the viewer by queryAlbums.
+const albumColumns = `a.id, a.owner, u.username, a.title, a.description, a.visibility, a.created_at,
+	COALESCE(
+		(SELECT photo.id FROM photo WHERE photo.id = a.cover AND ` + photoListedTo + `),
+		(SELECT ap.photo FROM album_photo ap JOIN photo ON photo.id = ap.photo WHERE ap.album = a.id AND ` + photoListedTo + `
+			ORDER BY ap.position LIMIT 1),
+		0),
+	(SELECT count(*) FROM album_photo ap JOIN photo ON photo.id = ap.photo WHERE ap.album = a.id AND ` + photoListedTo + `),
+	CASE WHEN a.owner = ? THEN 'owner' ELSE COALESCE((SELECT role FROM album_member WHERE album = a.id AND user = ?), '') END`
+
+// albumColumnsArgs returns the arguments for the placeholders of albumColumns.
+func albumColumnsArgs(viewer int64) []interface{} {
+	var args []interface{}
+	for i := 0; i < 3; i++ {
+		args = append(args, listedToArgs(viewer)...)
+	}
+	return append(args, viewer, viewer)
+}
+
+// albumVisibleTo is the SQL condition selecting the albums a user can see: the owner and the members see the album,
+// anybody else only public albums, provided the owner and the user did not ban each other. The placeholders must be
+// bound with albumVisibleToArgs.
+const albumVisibleTo = `(a.owner = ? OR a.id IN (SELECT album FROM album_member WHERE user = ?) OR (
+	a.visibility = 'public'
+	AND a.owner NOT IN (SELECT banning FROM ban WHERE banned = ?)
+	AND a.owner NOT IN (SELECT banned FROM ban WHERE banning = ?)))`
+
+// albumVisibleToArgs returns the arguments for the placeholders of albumVisibleTo.
+func albumVisibleToArgs(viewer int64) []interface{} {
+	return []interface{}{viewer, viewer, viewer, viewer}
+}
+
+// queryAlbums runs a query selecting albumColumns, with the role of the viewer, and returns the albums.
+func (db *appdbimpl) queryAlbums(viewer int64, query string, args ...interface{}) ([]Album, error) {
+	rows, err := db.c.Query(query, append(albumColumnsArgs(viewer), args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var albums []Album
+	for rows.Next() {
+		var album Album
+		if err := rows.Scan(&album.Id, &album.Owner, &album.OwnerUsername, &album.Title, &album.Description, &album.Visibility,
+			&album.CreatedAt, &album.CoverPhoto, &album.NumberOfPhotos, &album.Role); err != nil {
+			return nil, err
+		}
+		albums = append(albums, album)
+	}
+	return albums, rows.Err()
+}
+
+// CreateAlbum creates an empty album and returns its id.
+func (db *appdbimpl) CreateAlbum(owner int64, title string, description string, visibility string) (int64, error) {
+	res, err := db.c.Exec("INSERT INTO album (owner, title, description, visibility, created_at) VALUES (?, ?, ?, ?, ?)",
+		owner, title, description, visibility, formatTime(globaltime.Now()))
+	if err != nil {
+		return -1, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateAlbum changes the settings of the album. A zero cover means the first photo of the album.
+func (db *appdbimpl) UpdateAlbum(albumId int64, title string, description string, visibility string, cover int64) error {
+	var coverPhoto interface{}
+	if cover != 0 {
+		coverPhoto = cover
+	}
+	return db.execQuery("UPDATE album SET title=?, description=?, visibility=?, cover=? WHERE id=?",
+		title, description, visibility, coverPhoto, albumId)
+}
+
+// DeleteAlbum deletes the album with its members. The photos are not deleted.
+func (db *appdbimpl) DeleteAlbum(albumId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, query := range []string{
+		"DELETE FROM album_photo WHERE album=?",
+		"DELETE FROM album_member WHERE album=?",
+		"DELETE FROM album WHERE id=?",
+	} {
+		if _, err := tx.Exec(query, albumId); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAlbumRole returns the role of the user in the album, or an empty string if the user is not a member. It returns
+// sql.ErrNoRows if the album does not exist.
+func (db *appdbimpl) GetAlbumRole(albumId int64, user int64) (string, error) {
+	var role string
+	err := db.c.QueryRow("SELECT CASE WHEN owner = ? THEN 'owner' ELSE COALESCE((SELECT role FROM album_member WHERE album = album.id AND user = ?), '') END FROM album WHERE id=?",
+		user, user, albumId).Scan(&role)
+	return role, err
+}
+
+// CanViewAlbum checks if the album exists and the viewer can see it.
+func (db *appdbimpl) CanViewAlbum(albumId int64, viewer int64) (bool, error) {
+	args := append([]interface{}{albumId}, albumVisibleToArgs(viewer)...)
+	return db.checkExistence("SELECT count(*) FROM album a WHERE a.id=? AND "+albumVisibleTo, args...)
+}
+
+// GetAlbum returns the album with the photos visible to the viewer, in the album order. The photos of the users who
+// banned the viewer, or that the viewer banned, are left out.
+func (db *appdbimpl) GetAlbum(albumId int64, viewer int64) (Album, error) {
+	albums, err := db.queryAlbums(viewer, "SELECT "+albumColumns+" FROM album a JOIN user u ON u.token = a.owner WHERE a.id=?", albumId)
+	if err != nil {
+		return Album{}, err
+	}
+	if len(albums) == 0 {
+		return Album{}, sql.ErrNoRows
+	}
+	album := albums[0]
+
+	args := append([]interface{}{albumId}, listedToArgs(viewer)...)
+	album.Photos, err = db.queryPhotos(viewer, nil, "SELECT "+photoColumns+" FROM album_photo ap JOIN photo ON photo.id = ap.photo JOIN user u ON u.token = photo.owner WHERE ap.album=? AND "+photoListedTo+" ORDER BY ap.position", args...)
+	return album, err
+}
+
+// GetMyAlbums returns the albums owned by the user and the ones they are a member of.
+func (db *appdbimpl) GetMyAlbums(token int64) ([]Album, error) {
+	return db.queryAlbums(token, "SELECT "+albumColumns+" FROM album a JOIN user u ON u.token = a.owner WHERE a.owner=? OR a.id IN (SELECT album FROM album_member WHERE user=?) ORDER BY a.created_at DESC", token, token)
+}
+
+// GetAlbumsOf returns the albums owned by the user that the viewer can see.
+func (db *appdbimpl) GetAlbumsOf(viewer int64, owner int64) ([]Album, error) {
+	args := append([]interface{}{owner}, albumVisibleToArgs(viewer)...)
+	return db.queryAlbums(viewer, "SELECT "+albumColumns+" FROM album a JOIN user u ON u.token = a.owner WHERE a.owner=? AND "+albumVisibleTo+" ORDER BY a.created_at DESC", args...)
+}
+
+// AddAlbumPhoto adds the photo at the end of the album.
+func (db *appdbimpl) AddAlbumPhoto(albumId int64, photoId int64, addedBy int64) error {
+	return db.execQuery("INSERT INTO album_photo (album, photo, added_by, position, added_at) VALUES (?, ?, ?, (SELECT COALESCE(max(position), 0) + 1 FROM album_photo WHERE album=?), ?)",
+		albumId, photoId, addedBy, albumId, formatTime(globaltime.Now()))
+}
+
+// RemoveAlbumPhoto removes the photo from the album, and from its cover.
+func (db *appdbimpl) RemoveAlbumPhoto(albumId int64, photoId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM album_photo WHERE album=? AND photo=?", albumId, photoId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE album SET cover=NULL WHERE id=? AND cover=?", albumId, photoId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetAlbumPhotoAdder returns the user who added the photo to the album. It returns sql.ErrNoRows if the photo is not
+// in the album.
+func (db *appdbimpl) GetAlbumPhotoAdder(albumId int64, photoId int64) (int64, error) {
+	var addedBy int64
+	err := db.c.QueryRow("SELECT added_by FROM album_photo WHERE album=? AND photo=?", albumId, photoId).Scan(&addedBy)
+	return addedBy, err
+}
+
+// GetAlbumPhotoIds returns the ids of all the photos in the album, in the album order.
+func (db *appdbimpl) GetAlbumPhotoIds(albumId int64) ([]int64, error) {
+	rows, err := db.c.Query("SELECT photo FROM album_photo WHERE album=? ORDER BY position", albumId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetAlbumOrder reorders the photos of the album. The list must contain every photo of the album once.
+func (db *appdbimpl) SetAlbumOrder(albumId int64, photos []int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for i, photoId := range photos {
+		if _, err := tx.Exec("UPDATE album_photo SET position=? WHERE album=? AND photo=?", i+1, albumId, photoId); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetAlbumMember adds the user to the album with the given role, or changes their role.
+func (db *appdbimpl) SetAlbumMember(albumId int64, user int64, role string) error {
+	return db.execQuery("INSERT INTO album_member (album, user, role) VALUES (?, ?, ?) ON CONFLICT (album, user) DO UPDATE SET role=excluded.role",
+		albumId, user, role)
+}
+
+// RemoveAlbumMember removes the user from the album, along with the photos they added.
+func (db *appdbimpl) RemoveAlbumMember(albumId int64, user int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := removeAlbumMembers(tx, "album=? AND user=?", albumId, user); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// removeAlbumMembers removes the memberships matching the condition on `album_member`, along with the photos those
+// members added to the albums.
+func removeAlbumMembers(tx *sql.Tx, condition string, args ...interface{}) error {
+	rows, err := tx.Query("SELECT album, user FROM album_member WHERE "+condition, args...)
+	if err != nil {
+		return err
+	}
+	var members [][2]int64
+	for rows.Next() {
+		var member [2]int64
+		if err := rows.Scan(&member[0], &member[1]); err != nil {
+			rows.Close()
+			return err
+		}
+		members = append(members, member)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		album, user := member[0], member[1]
+		_, err := tx.Exec("UPDATE album SET cover=NULL WHERE id=? AND cover IN (SELECT photo FROM album_photo WHERE album=? AND added_by=?)", album, album, user)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM album_photo WHERE album=? AND added_by=?", album, user); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM album_member WHERE album=? AND user=?", album, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAlbumMembers returns the members of the album, without the owner, skipping the users who banned the viewer or
+// were banned by them.
+func (db *appdbimpl) GetAlbumMembers(albumId int64, viewer int64) ([]AlbumMember, error) {
+	rows, err := db.c.Query(`SELECT u.username, m.role FROM album_member m JOIN user u ON u.token = m.user WHERE m.album=?
+		AND m.user NOT IN (SELECT banning FROM ban WHERE banned = ?) AND m.user NOT IN (SELECT banned FROM ban WHERE banning = ?)
+		ORDER BY u.username`, albumId, viewer, viewer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []AlbumMember
+	for rows.Next() {
+		var member AlbumMember
+		if err := rows.Scan(&member.Username, &member.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
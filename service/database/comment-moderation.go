@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Who can comment a photo, besides its owner
+const (
+	CommentsEveryone  = "everyone"
+	CommentsFollowers = "followers"
+	CommentsOff       = "off"
+)
+
+// GetCommentsMode returns who can comment the photo.
+func (db *appdbimpl) GetCommentsMode(photoId int64) (string, error) {
+	var mode string
+	err := db.c.QueryRow("SELECT comments FROM photo WHERE id=?", photoId).Scan(&mode)
+	return mode, err
+}
+
+// SetCommentsMode changes who can comment the photo. The existing comments are kept.
+func (db *appdbimpl) SetCommentsMode(photoId int64, mode string) error {
+	return db.execQuery("UPDATE photo SET comments=? WHERE id=?", mode, photoId)
+}
+
+// GetCommentFilter returns the keywords that hide the comments on the photos of the user, in alphabetical order.
+func (db *appdbimpl) GetCommentFilter(token int64) ([]string, error) {
+	rows, err := db.c.Query("SELECT keyword FROM comment_filter WHERE user=? ORDER BY keyword", token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keywords []string
+	for rows.Next() {
+		var keyword string
+		if err := rows.Scan(&keyword); err != nil {
+			return nil, err
+		}
+		keywords = append(keywords, keyword)
+	}
+	return keywords, rows.Err()
+}
+
+// SetCommentFilter replaces the keywords that hide the comments on the photos of the user. The keywords must be
+// lowercase. They apply to the comments posted or edited from now on.
+func (db *appdbimpl) SetCommentFilter(token int64, keywords []string) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM comment_filter WHERE user=?", token); err != nil {
+		return err
+	}
+	for _, keyword := range keywords {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO comment_filter (user, keyword) VALUES (?, ?)", token, keyword); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// filterComment hides the comment if it contains one of the keywords of the photo owner, or shows it otherwise, and
+// returns whether it is hidden. The comments of the photo owner are never hidden.
+func filterComment(tx *sql.Tx, commentId int64) (bool, error) {
+	var content string
+	var author, photoOwner int64
+	err := tx.QueryRow("SELECT c.content, c.owner, p.owner FROM comment c JOIN photo p ON p.id = c.photo WHERE c.id=?", commentId).
+		Scan(&content, &author, &photoOwner)
+	if err != nil {
+		return false, err
+	}
+
+	hidden := false
+	if author != photoOwner {
+		hidden, err = matchesCommentFilter(tx, photoOwner, content)
+		if err != nil {
+			return false, err
+		}
+	}
+	_, err = tx.Exec("UPDATE comment SET hidden=? WHERE id=?", hidden, commentId)
+	return hidden, err
+}
+
+// matchesCommentFilter checks if the text contains one of the keywords of the user, ignoring the case.
+func matchesCommentFilter(tx *sql.Tx, user int64, text string) (bool, error) {
+	rows, err := tx.Query("SELECT keyword FROM comment_filter WHERE user=?", user)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	text = strings.ToLower(text)
+	for rows.Next() {
+		var keyword string
+		if err := rows.Scan(&keyword); err != nil {
+			return false, err
+		}
+		if strings.Contains(text, keyword) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// setCommentMentions applies the keyword filter of the photo owner to the comment and updates its mentions. The users
+// mentioned in a hidden comment are not notified.
+func setCommentMentions(tx *sql.Tx, commentId int64) error {
+	hidden, err := filterComment(tx, commentId)
+	if err != nil {
+		return err
+	}
+
+	var content string
+	var author, photoId int64
+	if err := tx.QueryRow("SELECT content, owner, photo FROM comment WHERE id=?", commentId).Scan(&content, &author, &photoId); err != nil {
+		return err
+	}
+	kind := NotificationCommentMention
+	if hidden {
+		kind = ""
+	}
+	return setMentions(tx, commentMentions, commentId, author, content, kind, photoId)
+}
+
+// GetHiddenComments returns the comments hidden by the keyword filter on the photos of the user, most recent first.
+func (db *appdbimpl) GetHiddenComments(token int64) ([]FullDataComment, error) {
+	return db.queryComments(token, "SELECT "+commentColumns+` FROM comment JOIN user u ON u.token = comment.owner
+		WHERE comment.hidden = 1 AND comment.deleted_at IS NULL AND comment.photo IN (SELECT id FROM photo WHERE owner=? AND deleted_at IS NULL)
+		ORDER BY comment.created_at DESC, comment.id DESC`, token)
+}
+
+// CheckHiddenComment checks if the comment is hidden by the keyword filter on a photo of the user.
+func (db *appdbimpl) CheckHiddenComment(token int64, commentId int64) (bool, error) {
+	return db.checkExistence(`SELECT count(*) FROM comment WHERE id=? AND hidden = 1 AND deleted_at IS NULL
+		AND photo IN (SELECT id FROM photo WHERE owner=?)`, commentId, token)
+}
+
+// ApproveComment shows a comment hidden by the keyword filter, and notifies the users mentioned in it. It is hidden
+// again if it is edited and still matches.
+func (db *appdbimpl) ApproveComment(commentId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("UPDATE comment SET hidden=0 WHERE id=?", commentId); err != nil {
+		return err
+	}
+	var author, photoId int64
+	if err := tx.QueryRow("SELECT owner, photo FROM comment WHERE id=?", commentId).Scan(&author, &photoId); err != nil {
+		return err
+	}
+	if err := notifyMentions(tx, commentMentions, commentId, author, NotificationCommentMention, photoId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxReplyDepth is how deep the replies can be nested: the replies to a top-level comment have depth 1, and the
+// replies to them depth 2.
+const MaxReplyDepth = 2
+
+// The orders of the top-level comments of a photo
+const (
+	// CommentSortNewest puts the most recent comments first
+	CommentSortNewest = "newest"
+
+	// CommentSortOldest puts the oldest comments first
+	CommentSortOldest = "oldest"
+
+	// CommentSortTop puts the most liked comments first, and the most recent first among those with the same likes
+	CommentSortTop = "top"
+)
+
+// ErrInvalidCursor is returned when a cursor was not returned by a previous page with the same order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// commentLikes is the number of likes of the comment, for the queries on `comment`
+const commentLikes = "(SELECT count(*) FROM comment_like WHERE comment = comment.id)"
+
+// commentCursor is the position of a comment in a page of comments. The likes are only used by CommentSortTop.
+type commentCursor struct {
+	likes     int64
+	createdAt time.Time
+	id        int64
+}
+
+// encode returns the opaque form of the cursor given to the clients
+func (c commentCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d|%d|%d", c.likes, c.createdAt.Unix(), c.id)))
+}
+
+// decodeCommentCursor parses a cursor returned by encode
+func decodeCommentCursor(value string) (commentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return commentCursor{}, ErrInvalidCursor
+	}
+	var c commentCursor
+	var createdAt int64
+	if n, err := fmt.Sscanf(string(raw), "%d|%d|%d", &c.likes, &createdAt, &c.id); err != nil || n != 3 {
+		return commentCursor{}, ErrInvalidCursor
+	}
+	c.createdAt = time.Unix(createdAt, 0)
+	return c, nil
+}
+
+// commentVisibleTo is the SQL condition selecting the rows of `comment` that a user can see: the comments hidden by
+// the keyword filter of the photo owner are only seen by their author and the photo owner. The placeholder must be
+// bound with the user.
+const commentVisibleTo = `(comment.hidden = 0 OR ? IN (comment.owner, (SELECT owner FROM photo WHERE id = comment.photo)))`
+
+// commentColumns are the columns scanned by queryComments. The queries must join the author of the comment as `u`.
+// Tombstones are returned without their author. The placeholders are bound to the viewer by queryComments.
+const commentColumns = `comment.id, comment.content, comment.created_at,
+	CASE WHEN comment.deleted_at IS NULL THEN u.username ELSE '' END, comment.photo, comment.parent_id,
+	comment.deleted_at IS NOT NULL,
+	(SELECT count(*) FROM comment r WHERE r.parent_id = comment.id
+		AND (r.hidden = 0 OR ? IN (r.owner, (SELECT owner FROM photo WHERE id = r.photo)))),
+	comment.edited_at, (SELECT owner FROM photo WHERE id = comment.photo), ` + commentLikes + `,
+	EXISTS (SELECT 1 FROM comment_like WHERE comment = comment.id AND owner = ?), comment.hidden`
+
+// commentHistoryPath returns the path of the API endpoint with the previous versions of the comment
+func commentHistoryPath(photoOwner int64, photoId int64, commentId int64) string {
+	return fmt.Sprintf("/user/%d/photos/%d/comments/%d/history/", photoOwner, photoId, commentId)
+}
+
+// queryComments runs a query selecting commentColumns and returns the comments as seen by the viewer
+func (db *appdbimpl) queryComments(viewer int64, query string, args ...interface{}) ([]FullDataComment, error) {
+	rows, err := db.c.Query(query, append([]interface{}{viewer, viewer}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []FullDataComment
+	for rows.Next() {
+		var comment FullDataComment
+		var parentId sql.NullInt64
+		var editedAt sql.NullString
+		var photoOwner int64
+		if err := rows.Scan(&comment.Id, &comment.Content, &comment.CreatedAt, &comment.Owner, &comment.Photo, &parentId,
+			&comment.Deleted, &comment.NumberOfReplies, &editedAt, &photoOwner, &comment.NumberOfLikes, &comment.IsLiked, &comment.Hidden); err != nil {
+			return nil, err
+		}
+		if parentId.Valid {
+			comment.ParentId = &parentId.Int64
+		}
+		if editedAt.Valid {
+			comment.EditedAt = editedAt.String
+			comment.History = commentHistoryPath(photoOwner, comment.Photo, comment.Id)
+		}
+		comment.Mentions, err = db.getMentions(commentMentions, comment.Id)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// GetCommentReplies returns the direct replies to the comment, the oldest first so that the thread reads in order.
+func (db *appdbimpl) GetCommentReplies(commentId int64, viewer int64, offset int, limit int) ([]FullDataComment, error) {
+	return db.queryComments(viewer, "SELECT "+commentColumns+` FROM comment JOIN user u ON u.token = comment.owner
+		WHERE comment.parent_id=? AND `+commentVisibleTo+` ORDER BY comment.created_at, comment.id LIMIT ? OFFSET ?`, commentId, viewer, limit, offset)
+}
+
+// GetCommentDepth returns the photo of the comment, its depth in the thread (0 for the top-level comments) and whether
+// it is a tombstone. It returns sql.ErrNoRows if the comment does not exist.
+func (db *appdbimpl) GetCommentDepth(commentId int64) (int64, int, bool, error) {
+	var photoId int64
+	var depth int
+	var deleted bool
+	err := db.c.QueryRow(`SELECT c.photo, (c.parent_id IS NOT NULL) + (p.parent_id IS NOT NULL), c.deleted_at IS NOT NULL
+		FROM comment c LEFT JOIN comment p ON p.id = c.parent_id WHERE c.id=?`, commentId).Scan(&photoId, &depth, &deleted)
+	return photoId, depth, deleted, err
+}
+
+// removeComment deletes the comment, or turns it into a tombstone if it has replies so that the thread stays readable.
+// The tombstones left without replies are deleted too.
+func removeComment(tx *sql.Tx, commentId int64) error {
+	var replies int64
+	var parentId sql.NullInt64
+	err := tx.QueryRow("SELECT (SELECT count(*) FROM comment WHERE parent_id = c.id), c.parent_id FROM comment c WHERE c.id=?", commentId).
+		Scan(&replies, &parentId)
+	if err != nil {
+		return err
+	}
+	// The history, the likes and the mentions of the comment go with its content
+	for _, query := range []string{
+		"DELETE FROM comment_revision WHERE comment=?",
+		"DELETE FROM comment_like WHERE comment=?",
+		"DELETE FROM comment_mention WHERE id=?",
+	} {
+		if _, err := tx.Exec(query, commentId); err != nil {
+			return err
+		}
+	}
+	if replies > 0 {
+		_, err := tx.Exec("UPDATE comment SET content='', deleted_at=?, edited_at=NULL WHERE id=?", formatTime(globaltime.Now()), commentId)
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM comment WHERE id=?", commentId); err != nil {
+		return err
+	}
+
+	// Walk up the thread deleting the tombstones that had this comment as their last reply
+	for parentId.Valid {
+		tombstoneId := parentId.Int64
+		var orphan bool
+		err := tx.QueryRow(`SELECT deleted_at IS NOT NULL AND NOT EXISTS (SELECT 1 FROM comment r WHERE r.parent_id = c.id), c.parent_id
+			FROM comment c WHERE c.id=?`, tombstoneId).Scan(&orphan, &parentId)
+		if err != nil || !orphan {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM comment WHERE id=?", tombstoneId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EditComment replaces the content of the comment, keeping the previous one in its history.
+func (db *appdbimpl) EditComment(commentId int64, content string) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.Exec(`INSERT INTO comment_revision (comment, revision, content, created_at)
+		SELECT id, (SELECT count(*) + 1 FROM comment_revision WHERE comment = comment.id), content, COALESCE(edited_at, created_at)
+		FROM comment WHERE id=?`, commentId)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE comment SET content=?, edited_at=? WHERE id=?", content, formatTime(globaltime.Now()), commentId); err != nil {
+		return err
+	}
+	if err := setCommentMentions(tx, commentId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCommentHistory returns the previous versions of the comment, the oldest first.
+func (db *appdbimpl) GetCommentHistory(commentId int64) ([]CommentRevision, error) {
+	rows, err := db.c.Query("SELECT revision, content, created_at FROM comment_revision WHERE comment=? ORDER BY revision", commentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []CommentRevision
+	for rows.Next() {
+		var revision CommentRevision
+		if err := rows.Scan(&revision.Revision, &revision.Content, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// LikeComment adds the like of the user to the comment.
+func (db *appdbimpl) LikeComment(token int64, commentId int64) error {
+	return db.execQuery("INSERT INTO comment_like (comment, owner, created_at) VALUES (?, ?, ?)", commentId, token, formatTime(globaltime.Now()))
+}
+
+// UnlikeComment removes the like of the user from the comment.
+func (db *appdbimpl) UnlikeComment(token int64, commentId int64) error {
+	return db.execQuery("DELETE FROM comment_like WHERE comment=? AND owner=?", commentId, token)
+}
+
+// CheckCommentLike checks if the user liked the comment.
+func (db *appdbimpl) CheckCommentLike(token int64, commentId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM comment_like WHERE comment=? AND owner=?", commentId, token)
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetPhotoCommentsPages(t *testing.T) {
+	db := newTestDatabase(t)
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	owner, err := db.GetUserToken("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFixedTime(t, start)
+	if err := db.PostPhoto([]byte("photo"), owner, PhotoOptions{Visibility: VisibilityPublic}); err != nil {
+		t.Fatal(err)
+	}
+	const photoId = 1
+
+	// Comments 1 and 2 are posted at the same time, as are 4 and 5, to check the ties on the time
+	comment := func(at time.Duration, parentId *int64) int64 {
+		t.Helper()
+		setFixedTime(t, start.Add(at))
+		commentId, err := db.CommentPhoto(owner, photoId, parentId, "comment")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return commentId
+	}
+	var ids []int64
+	for _, at := range []time.Duration{0, 0, time.Minute, 2 * time.Minute, 2 * time.Minute} {
+		ids = append(ids, comment(at, nil))
+	}
+
+	// Replies are not in the pages of the top-level comments
+	comment(3*time.Minute, &ids[0])
+
+	// Comments 1 and 3 have the same likes, to check the ties on the likes
+	likes := map[int64][]string{ids[0]: {"bob", "carol"}, ids[2]: {"bob", "carol"}, ids[3]: {"bob"}}
+	for commentId, users := range likes {
+		for _, username := range users {
+			user, err := db.GetUserToken(username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.LikeComment(user, commentId); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		sort string
+		want []int64
+	}{
+		{CommentSortNewest, []int64{5, 4, 3, 2, 1}},
+		{CommentSortOldest, []int64{1, 2, 3, 4, 5}},
+		{CommentSortTop, []int64{3, 1, 4, 5, 2}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 5, 10} {
+			var got []int64
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > len(tt.want) {
+					t.Fatalf("%s with limit %d: the pages do not end", tt.sort, limit)
+				}
+				page, err := db.GetPhotoComments(photoId, owner, tt.sort, cursor, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Comments) > limit {
+					t.Errorf("%s with limit %d: got a page of %d comments", tt.sort, limit, len(page.Comments))
+				}
+				for _, c := range page.Comments {
+					got = append(got, c.Id)
+				}
+				if page.Next == "" {
+					break
+				}
+				cursor = page.Next
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s with limit %d: got comments %v, want %v", tt.sort, limit, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestGetPhotoCommentsInvalidCursor(t *testing.T) {
+	db := newTestDatabase(t)
+	owner, err := db.GetUserToken("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	for _, cursor := range []string{"not base64!", encode("1|2"), encode("a|b|c"), encode("1|2|x")} {
+		_, err := db.GetPhotoComments(1, owner, CommentSortNewest, cursor, 10)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: got error %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
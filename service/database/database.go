@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // AppDatabase is the high-level interface for the DB.
@@ -31,16 +32,162 @@ type AppDatabase interface {
 	CheckFollow(u1 int64, u2 int64) (bool, error)
 	CheckBan(u1 int64, u2 int64) (bool, error)
 
-	PostPhoto(image []byte, token int64) error
+	PostPhoto(image []byte, token int64, options PhotoOptions) error
 	DeletePhoto(token int64, photoId int64) error
 	GetImage(photoId int64) ([]byte, error)
+	GetPoster(photoId int64) ([]byte, error)
 	LikePhoto(token int64, photoId int64) error
 	UnlikePhoto(token int64, photoId int64) error
-	CommentPhoto(token int64, photoId int64, content string) (int64, error)
-	GetPhotoComments(photoId int64) ([]FullDataComment, error)
+	GetLikers(photoId int64, viewer int64, offset int, limit int) ([]Liker, error)
+	SetReaction(token int64, photoId int64, reaction string) error
+	RemoveReaction(token int64, photoId int64) error
+	GetReaction(token int64, photoId int64) (string, error)
+	GetReactionCounts(photoId int64) (map[string]int64, error)
+	CommentPhoto(token int64, photoId int64, parentId *int64, content string) (int64, error)
+	GetPhotoComments(photoId int64, viewer int64, sort string, cursor string, limit int) (CommentsPage, error)
+	GetCommentReplies(commentId int64, viewer int64, offset int, limit int) ([]FullDataComment, error)
+	GetCommentDepth(commentId int64) (int64, int, bool, error)
+	GetComment(commentId int64, viewer int64) (FullDataComment, error)
+	EditComment(commentId int64, content string) error
+	GetCommentHistory(commentId int64) ([]CommentRevision, error)
+	LikeComment(token int64, commentId int64) error
+	UnlikeComment(token int64, commentId int64) error
+	CheckCommentLike(token int64, commentId int64) (bool, error)
+	GetCommentsMode(photoId int64) (string, error)
+	SetCommentsMode(photoId int64, mode string) error
+	GetCommentFilter(token int64) ([]string, error)
+	SetCommentFilter(token int64, keywords []string) error
+	GetHiddenComments(token int64) ([]FullDataComment, error)
+	CheckHiddenComment(token int64, commentId int64) (bool, error)
+	ApproveComment(commentId int64) error
 	GetCommentOwner(commentId int64) (int64, error)
 	DeleteComment(commentId int64) error
 	GetMyStream(token int64) ([]Photo, error)
+
+	CanViewPhoto(viewer int64, photoId int64) (bool, error)
+	SetPhotoVisibility(photoId int64, visibility string) error
+	AddCloseFriend(owner int64, friend string) error
+	RemoveCloseFriend(owner int64, friend string) error
+	CheckCloseFriend(owner int64, friend int64) (bool, error)
+
+	GetTrash(token int64) ([]Photo, error)
+	CheckTrashedPhoto(token int64, photoId int64) (bool, error)
+	RestorePhoto(token int64, photoId int64) error
+	PurgePhoto(photoId int64) error
+	PurgeTrash(before time.Time) (int64, error)
+
+	GetDrafts(token int64) ([]Photo, error)
+	CheckDraft(token int64, photoId int64) (bool, error)
+	PublishPhoto(photoId int64) error
+	SchedulePhoto(photoId int64, publishAt time.Time) error
+	PublishScheduledPhotos(now time.Time) (int64, error)
+
+	GetEditRecipe(photoId int64) (string, error)
+	SetEditRecipe(photoId int64, recipe string) error
+
+	SetPhotoLocation(photoId int64, location PhotoLocation) error
+	ClearPhotoLocation(photoId int64) error
+	GetPhotosInBox(viewer int64, west, south, east, north float64, limit int) ([]Photo, error)
+	GetUserMap(viewer int64, owner int64) ([]Photo, error)
+
+	SetCaption(photoId int64, caption string) error
+	EditPhoto(photoId int64, edit PhotoEdit) error
+	ReplaceImage(photoId int64, image []byte, animation *PhotoAnimation) error
+	GetPhotoHistory(photoId int64) ([]PhotoRevision, error)
+	GetRevisionImage(photoId int64, revision int64) ([]byte, error)
+	GetTag(viewer int64, name string) (Tag, error)
+	GetTagPhotos(viewer int64, name string, before int64, limit int) ([]Photo, error)
+
+	TagPerson(photoId int64, taggedBy int64, user int64, x float64, y float64) (string, bool, error)
+	CheckPersonTag(photoId int64, user int64) (bool, error)
+	CheckPendingPersonTag(photoId int64, user int64) (bool, error)
+	ApprovePersonTag(photoId int64, user int64) error
+	RemovePersonTag(photoId int64, user int64) error
+	GetPhotoPeople(photoId int64, viewer int64) ([]PersonTag, error)
+	GetPendingTaggedPhotos(token int64) ([]Photo, error)
+	GetPhotosOf(viewer int64, user int64) ([]Photo, error)
+	GetAutoApproveTags(token int64) (bool, error)
+	SetAutoApproveTags(token int64, autoApprove bool) error
+
+	GetNotifications(token int64, limit int) ([]Notification, error)
+	MarkNotificationsRead(token int64) error
+
+	RecordView(photoId int64, viewer int64) error
+	GetPhotoInsights(photoId int64, days int) (PhotoInsights, error)
+	GetProfileInsights(token int64, days int) (ProfileInsights, error)
+
+	PinPhoto(photoId int64) (bool, error)
+	UnpinPhoto(photoId int64) error
+	CheckPinned(photoId int64) (bool, error)
+
+	CreateAlbum(owner int64, title string, description string, visibility string) (int64, error)
+	UpdateAlbum(albumId int64, title string, description string, visibility string, cover int64) error
+	DeleteAlbum(albumId int64) error
+	GetAlbumRole(albumId int64, user int64) (string, error)
+	CanViewAlbum(albumId int64, viewer int64) (bool, error)
+	GetAlbum(albumId int64, viewer int64) (Album, error)
+	GetMyAlbums(token int64) ([]Album, error)
+	GetAlbumsOf(viewer int64, owner int64) ([]Album, error)
+	AddAlbumPhoto(albumId int64, photoId int64, addedBy int64) error
+	RemoveAlbumPhoto(albumId int64, photoId int64) error
+	GetAlbumPhotoAdder(albumId int64, photoId int64) (int64, error)
+	GetAlbumPhotoIds(albumId int64) ([]int64, error)
+	SetAlbumOrder(albumId int64, photos []int64) error
+	SetAlbumMember(albumId int64, user int64, role string) error
+	RemoveAlbumMember(albumId int64, user int64) error
+	GetAlbumMembers(albumId int64, viewer int64) ([]AlbumMember, error)
+
+	SavePhoto(token int64, photoId int64) error
+	UnsavePhoto(token int64, photoId int64) error
+	CheckSaved(token int64, photoId int64) (bool, error)
+	GetSavedPhotos(token int64) ([]Photo, error)
+	CreateCollection(token int64, name string) (int64, error)
+	RenameCollection(collectionId int64, name string) error
+	DeleteCollection(collectionId int64) error
+	CheckCollectionOwner(token int64, collectionId int64) (bool, error)
+	CheckCollectionName(token int64, name string) (bool, error)
+	GetCollections(token int64) ([]Collection, error)
+	GetCollectionPhotos(token int64, collectionId int64) ([]Photo, error)
+	AddCollectionPhoto(token int64, collectionId int64, photoId int64) error
+	RemoveCollectionPhoto(collectionId int64, photoId int64) error
+	CheckCollectionPhoto(collectionId int64, photoId int64) (bool, error)
+
+	PostStory(token int64, image []byte) (int64, error)
+	DeleteStory(storyId int64) error
+	CheckStoryOwner(token int64, storyId int64) (bool, error)
+	CanViewStory(viewer int64, storyId int64) (bool, error)
+	GetStoryImage(storyId int64) ([]byte, error)
+	RecordStoryView(storyId int64, viewer int64) error
+	GetStoryViewers(storyId int64) ([]StoryViewer, error)
+	GetMyStories(token int64) ([]Story, error)
+	GetStoriesOf(viewer int64, owner int64) ([]Story, error)
+	GetStoryTray(viewer int64) ([]StoryTrayEntry, error)
+	GetStoryArchive(token int64) ([]Story, error)
+	GetArchiveStories(token int64) (bool, error)
+	SetArchiveStories(token int64, archive bool) error
+	ExpireStories(now time.Time) (int64, error)
+
+	Repost(token int64, photoId int64) error
+	RemoveRepost(token int64, photoId int64) error
+	CheckRepost(token int64, photoId int64) (bool, error)
+
+	GetUsername(token int64) (string, error)
+	GetWatermark(owner int64) (Watermark, error)
+	SetWatermark(owner int64, kind string, position string, opacity float64) error
+	DeleteWatermark(owner int64) error
+	GetWatermarkImage(owner int64) ([]byte, error)
+	SetWatermarkImage(owner int64, image []byte) error
+
+	AddBlockedUpload(token int64, matchType string, hash string) error
+	GetBlockedUploads() ([]BlockedUpload, error)
+}
+
+// timeFormat is the layout of the DATETIME columns, the same used by CURRENT_TIMESTAMP
+const timeFormat = "2006-01-02 15:04:05"
+
+// formatTime converts a time to the DATETIME layout so that it can be compared with the other timestamps in SQL
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
 }
 
 type appdbimpl struct {
@@ -69,7 +216,8 @@ func (db *appdbimpl) Ping() error {
 	return db.c.Ping()
 }
 
-// checkAndCreateTables verifies if the necessary tables exist, and creates them if they do not.
+// checkAndCreateTables verifies if the necessary tables exist, and creates them if they do not. The databases created
+// by a previous version are migrated to the current structure.
 func checkAndCreateTables(db *sql.DB) error {
 	var tableName string
 	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type='table' AND name='user';`).Scan(&tableName)
@@ -77,22 +225,191 @@ func checkAndCreateTables(db *sql.DB) error {
 		sqlStmt := `
 			CREATE TABLE user (
 				token    INTEGER PRIMARY KEY AUTOINCREMENT,
-				username TEXT NOT NULL UNIQUE
+				username TEXT NOT NULL UNIQUE,
+				auto_approve_tags INTEGER NOT NULL DEFAULT 0,
+				archive_stories   INTEGER NOT NULL DEFAULT 0
 			);
 
 			CREATE TABLE photo (
 				id         INTEGER PRIMARY KEY AUTOINCREMENT,
 				owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
 				img        BLOB NOT NULL,
-				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				visibility TEXT NOT NULL DEFAULT 'public'
+					CHECK (visibility IN ('public', 'followers', 'close_friends', 'only_me')),
+				deleted_at DATETIME,
+				published  INTEGER NOT NULL DEFAULT 1,
+				publish_at DATETIME,
+				edit_recipe TEXT,
+				is_animated INTEGER NOT NULL DEFAULT 0,
+				frame_count INTEGER NOT NULL DEFAULT 1,
+				duration_ms INTEGER NOT NULL DEFAULT 0,
+				poster      BLOB,
+				latitude    REAL,
+				longitude   REAL,
+				place_name  TEXT,
+				caption     TEXT NOT NULL DEFAULT '',
+				pinned_at   DATETIME,
+				edited_at   DATETIME,
+				comments    TEXT NOT NULL DEFAULT 'everyone' CHECK (comments IN ('everyone', 'followers', 'off'))
 			);
 
-			CREATE TABLE likes (
-				owner INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+			CREATE TABLE photo_revision (
+				photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				revision   INTEGER NOT NULL,
+				caption    TEXT NOT NULL,
+				latitude   REAL,
+				longitude  REAL,
+				place_name TEXT,
+				img        BLOB,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY (photo, revision)
+			);
+
+			CREATE VIRTUAL TABLE photo_location USING rtree (
+				id,
+				min_lat, max_lat,
+				min_lon, max_lon
+			);
+
+			CREATE TABLE tag (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				name        TEXT NOT NULL UNIQUE,
+				usage_count INTEGER NOT NULL DEFAULT 0
+			);
+
+			CREATE TABLE photo_tag (
 				photo INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				tag   INTEGER NOT NULL REFERENCES tag ON DELETE CASCADE,
+				PRIMARY KEY (photo, tag)
+			);
+
+			CREATE INDEX photo_tag_by_tag ON photo_tag (tag, photo);
+
+			CREATE TABLE photo_mention (
+				id       INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				user     INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				start    INTEGER NOT NULL,
+				length   INTEGER NOT NULL,
+				notified INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (id, start)
+			);
+
+			CREATE TABLE person_tag (
+				photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				user       INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				tagged_by  INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				x          REAL NOT NULL CHECK (x BETWEEN 0 AND 1),
+				y          REAL NOT NULL CHECK (y BETWEEN 0 AND 1),
+				status     TEXT NOT NULL CHECK (status IN ('pending', 'approved')),
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY (photo, user)
+			);
+
+			CREATE INDEX person_tag_by_user ON person_tag (user, status);
+
+			CREATE TABLE notification (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				recipient  INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				actor      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				kind       TEXT NOT NULL,
+				photo      INTEGER REFERENCES photo ON DELETE CASCADE,
+				created_at DATETIME NOT NULL,
+				read       INTEGER NOT NULL DEFAULT 0
+			);
+
+			CREATE INDEX notification_by_recipient ON notification (recipient, id);
+
+			CREATE TABLE album (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				owner       INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				title       TEXT NOT NULL,
+				description TEXT NOT NULL DEFAULT '',
+				visibility  TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'members')),
+				cover       INTEGER REFERENCES photo ON DELETE SET NULL,
+				created_at  DATETIME NOT NULL
+			);
+
+			CREATE TABLE album_member (
+				album INTEGER NOT NULL REFERENCES album ON DELETE CASCADE,
+				user  INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				role  TEXT NOT NULL CHECK (role IN ('contributor', 'viewer')),
+				PRIMARY KEY (album, user)
+			);
+
+			CREATE TABLE album_photo (
+				album    INTEGER NOT NULL REFERENCES album ON DELETE CASCADE,
+				photo    INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				added_by INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				added_at DATETIME NOT NULL,
+				PRIMARY KEY (album, photo)
+			);
+
+			CREATE TABLE saved (
+				owner    INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				photo    INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				saved_at DATETIME NOT NULL,
 				PRIMARY KEY (owner, photo)
 			);
 
+			CREATE TABLE collection (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				name       TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				UNIQUE (owner, name)
+			);
+
+			CREATE TABLE collection_photo (
+				collection INTEGER NOT NULL REFERENCES collection ON DELETE CASCADE,
+				photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				added_at   DATETIME NOT NULL,
+				PRIMARY KEY (collection, photo)
+			);
+
+			CREATE TABLE story (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				img        BLOB NOT NULL,
+				created_at DATETIME NOT NULL,
+				expires_at DATETIME NOT NULL,
+				archived   INTEGER NOT NULL DEFAULT 0
+			);
+
+			CREATE INDEX story_by_owner ON story (owner, expires_at);
+
+			CREATE TABLE story_view (
+				story     INTEGER NOT NULL REFERENCES story ON DELETE CASCADE,
+				viewer    INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				viewed_at DATETIME NOT NULL,
+				PRIMARY KEY (story, viewer)
+			);
+
+			CREATE TABLE likes (
+				owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				reaction   TEXT NOT NULL DEFAULT '❤️',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+				PRIMARY KEY (owner, photo)
+			);
+
+			CREATE TABLE repost (
+				owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY (owner, photo)
+			);
+
+			CREATE INDEX repost_by_photo ON repost (photo, created_at);
+
+			CREATE TABLE photo_view (
+				photo  INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+				viewer INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				day    TEXT NOT NULL,
+				PRIMARY KEY (photo, viewer, day)
+			);
+
 			CREATE TABLE follow (
 				following INTEGER NOT NULL REFERENCES user,
 				followed  INTEGER NOT NULL REFERENCES user,
@@ -100,12 +417,57 @@ func checkAndCreateTables(db *sql.DB) error {
 				CHECK (following != followed)
 			);
 
+			CREATE TABLE follow_event (
+				following  INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				followed   INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				kind       TEXT NOT NULL CHECK (kind IN ('follow', 'unfollow')),
+				created_at DATETIME NOT NULL
+			);
+
+			CREATE INDEX follow_event_by_followed ON follow_event (followed, created_at);
+
 			CREATE TABLE comment (
 				id         INTEGER PRIMARY KEY AUTOINCREMENT,
 				content    TEXT NOT NULL,
 				created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
 				owner      INTEGER NOT NULL REFERENCES user,
-				photo      INTEGER NOT NULL REFERENCES photo
+				photo      INTEGER NOT NULL REFERENCES photo,
+				parent_id  INTEGER REFERENCES comment,
+				deleted_at DATETIME,
+				edited_at  DATETIME,
+				hidden     INTEGER NOT NULL DEFAULT 0
+			);
+
+			CREATE INDEX comment_by_parent ON comment (parent_id, created_at);
+
+			CREATE TABLE comment_revision (
+				comment    INTEGER NOT NULL REFERENCES comment ON DELETE CASCADE,
+				revision   INTEGER NOT NULL,
+				content    TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY (comment, revision)
+			);
+
+			CREATE TABLE comment_like (
+				comment    INTEGER NOT NULL REFERENCES comment ON DELETE CASCADE,
+				owner      INTEGER NOT NULL REFERENCES user,
+				created_at DATETIME NOT NULL,
+				PRIMARY KEY (comment, owner)
+			);
+
+			CREATE TABLE comment_filter (
+				user    INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				keyword TEXT NOT NULL,
+				PRIMARY KEY (user, keyword)
+			);
+
+			CREATE TABLE comment_mention (
+				id       INTEGER NOT NULL REFERENCES comment ON DELETE CASCADE,
+				user     INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				start    INTEGER NOT NULL,
+				length   INTEGER NOT NULL,
+				notified INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (id, start)
 			);
 
 			CREATE TABLE ban (
@@ -113,11 +475,48 @@ func checkAndCreateTables(db *sql.DB) error {
 				banned  INTEGER NOT NULL REFERENCES user,
 				PRIMARY KEY (banning, banned),
 				CHECK (banning != banned)
+			);
+
+			CREATE TABLE close_friend (
+				owner  INTEGER NOT NULL REFERENCES user,
+				friend INTEGER NOT NULL REFERENCES user,
+				PRIMARY KEY (owner, friend),
+				CHECK (owner != friend)
+			);
+
+			CREATE TABLE watermark (
+				owner    INTEGER PRIMARY KEY REFERENCES user ON DELETE CASCADE,
+				kind     TEXT NOT NULL CHECK (kind IN ('username', 'image')),
+				position TEXT NOT NULL,
+				opacity  REAL NOT NULL
+			);
+
+			CREATE TABLE watermark_image (
+				owner INTEGER PRIMARY KEY REFERENCES user ON DELETE CASCADE,
+				img   BLOB NOT NULL
+			);
+
+			CREATE TABLE blocked_upload (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+				match_type TEXT NOT NULL,
+				hash       TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
 			);`
 		_, err = db.Exec(sqlStmt)
 		if err != nil {
 			return fmt.Errorf("error creating database structure: %w", err)
 		}
+
+		// The structure above is the latest one, so there is nothing to migrate
+		_, err = db.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(migrations)))
+		if err != nil {
+			return fmt.Errorf("error setting the database version: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error checking the database structure: %w", err)
 	}
-	return nil
+	return migrate(db)
 }
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"time"
+)
+
+// GetDrafts returns the unpublished photos of the user, both drafts and scheduled ones.
+func (db *appdbimpl) GetDrafts(token int64) ([]Photo, error) {
+	publishAt := func(photo *Photo) []interface{} {
+		photo.IsDraft = true
+		return []interface{}{&photo.PublishAt}
+	}
+	return db.queryPhotos(token, publishAt, "SELECT "+photoColumns+", COALESCE(publish_at, '') FROM photo JOIN user u ON u.token = photo.owner WHERE owner=? AND published = 0 AND deleted_at IS NULL ORDER BY created_at DESC", token)
+}
+
+// CheckDraft checks if the photo is an unpublished photo of the user.
+func (db *appdbimpl) CheckDraft(token int64, photoId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM photo WHERE id=? AND owner=? AND published = 0 AND deleted_at IS NULL", photoId, token)
+}
+
+// PublishPhoto publishes a draft right away, and notifies the users mentioned in it.
+func (db *appdbimpl) PublishPhoto(photoId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("UPDATE photo SET published=1, publish_at=NULL, created_at=? WHERE id=?", formatTime(globaltime.Now()), photoId); err != nil {
+		return err
+	}
+	if err := notifyPhotoMentions(tx, photoId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchedulePhoto sets when a draft will be published by the scheduler.
+func (db *appdbimpl) SchedulePhoto(photoId int64, publishAt time.Time) error {
+	return db.execQuery("UPDATE photo SET publish_at=? WHERE id=? AND published = 0", formatTime(publishAt), photoId)
+}
+
+// PublishScheduledPhotos publishes the photos scheduled at or before now, notifies the users mentioned in them, and
+// returns how many have been published. The creation date of each photo becomes its scheduled time, so that it is
+// sorted correctly in the streams.
+func (db *appdbimpl) PublishScheduledPhotos(now time.Time) (int64, error) {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	const due = "published = 0 AND deleted_at IS NULL AND publish_at <= ?"
+	rows, err := tx.Query("SELECT id FROM photo WHERE "+due, formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var photoIds []int64
+	for rows.Next() {
+		var photoId int64
+		if err := rows.Scan(&photoId); err != nil {
+			return 0, err
+		}
+		photoIds = append(photoIds, photoId)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	res, err := tx.Exec("UPDATE photo SET published=1, created_at=publish_at, publish_at=NULL WHERE "+due, formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	published, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	for _, photoId := range photoIds {
+		if err := notifyPhotoMentions(tx, photoId); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestDatabase returns an empty database in a temporary file, removed at the end of the test
+func newTestDatabase(t *testing.T) *appdbimpl {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	db, err := New(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db.(*appdbimpl)
+}
+
+// setFixedTime sets the time returned by globaltime.Now until the end of the test
+func setFixedTime(t *testing.T, now time.Time) {
+	t.Helper()
+	globaltime.FixedTime = now
+	t.Cleanup(func() { globaltime.FixedTime = time.Time{} })
+}
+
+func TestPublishScheduledPhotos(t *testing.T) {
+	db := newTestDatabase(t)
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	publishAt := start.Add(time.Hour)
+	setFixedTime(t, start)
+
+	owner, err := db.GetUserToken("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PostPhoto([]byte("scheduled"), owner, PhotoOptions{Visibility: "public", PublishAt: publishAt}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PostPhoto([]byte("draft"), owner, PhotoOptions{Visibility: "public", Draft: true}); err != nil {
+		t.Fatal(err)
+	}
+	const scheduled, draft = 1, 2
+
+	publish := func(now time.Time, want int64) {
+		t.Helper()
+		setFixedTime(t, now)
+		published, err := db.PublishScheduledPhotos(globaltime.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if published != want {
+			t.Errorf("at %v published %d photos, want %d", now, published, want)
+		}
+	}
+	isDraft := func(photoId int64) bool {
+		t.Helper()
+		isDraft, err := db.CheckDraft(owner, photoId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return isDraft
+	}
+
+	publish(publishAt.Add(-time.Second), 0)
+	if !isDraft(scheduled) {
+		t.Error("the scheduled photo was published before its time")
+	}
+
+	publish(publishAt, 1)
+	if isDraft(scheduled) {
+		t.Error("the scheduled photo was not published at its time")
+	}
+	var createdAt time.Time
+	if err := db.c.QueryRow("SELECT created_at FROM photo WHERE id=?", scheduled).Scan(&createdAt); err != nil {
+		t.Fatal(err)
+	}
+	if !createdAt.Equal(publishAt) {
+		t.Errorf("the published photo was created at %v, want %v", createdAt, publishAt)
+	}
+
+	publish(publishAt.Add(time.Hour), 0)
+	if !isDraft(draft) {
+		t.Error("the draft without a schedule was published")
+	}
+}
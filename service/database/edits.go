@@ -0,0 +1,19 @@
+package database
+
+import "database/sql"
+
+// GetEditRecipe returns the JSON edit recipe of a photo, or an empty string if the photo has not been edited.
+func (db *appdbimpl) GetEditRecipe(photoId int64) (string, error) {
+	var recipe sql.NullString
+	err := db.c.QueryRow("SELECT edit_recipe FROM photo WHERE id=?", photoId).Scan(&recipe)
+	return recipe.String, err
+}
+
+// SetEditRecipe stores the JSON edit recipe of a photo. An empty recipe reverts the photo to the original.
+func (db *appdbimpl) SetEditRecipe(photoId int64, recipe string) error {
+	var value interface{}
+	if recipe != "" {
+		value = recipe
+	}
+	return db.execQuery("UPDATE photo SET edit_recipe=? WHERE id=?", value, photoId)
+}
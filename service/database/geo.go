@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// PhotoLocation is the place attached to a photo by its owner
+type PhotoLocation struct {
+	Latitude  float64
+	Longitude float64
+
+	// Name is a free-text description of the place, may be empty
+	Name string
+}
+
+// setLocation stores the location in the photo row and in the photo_location R*Tree index, as a single point.
+func setLocation(tx *sql.Tx, photoId int64, location PhotoLocation) error {
+	_, err := tx.Exec("UPDATE photo SET latitude=?, longitude=?, place_name=? WHERE id=?",
+		location.Latitude, location.Longitude, location.Name, photoId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT OR REPLACE INTO photo_location (id, min_lat, max_lat, min_lon, max_lon) VALUES (?, ?, ?, ?, ?)",
+		photoId, location.Latitude, location.Latitude, location.Longitude, location.Longitude)
+	return err
+}
+
+// SetPhotoLocation attaches a location to the photo, replacing the previous one, which is kept in its history.
+func (db *appdbimpl) SetPhotoLocation(photoId int64, location PhotoLocation) error {
+	return db.EditPhoto(photoId, PhotoEdit{Location: &location})
+}
+
+// ClearPhotoLocation removes the location of the photo, which is kept in its history.
+func (db *appdbimpl) ClearPhotoLocation(photoId int64) error {
+	return db.EditPhoto(photoId, PhotoEdit{RemoveLocation: true})
+}
+
+// GetPhotosInBox returns the photos visible to the viewer located inside the bounding box, most recent first. The box
+// crosses the antimeridian when west is greater than east. Photos of users banned by the viewer are left out.
+func (db *appdbimpl) GetPhotosInBox(viewer int64, west, south, east, north float64, limit int) ([]Photo, error) {
+	var query strings.Builder
+	query.WriteString("SELECT " + photoColumns + " FROM photo_location l JOIN photo ON photo.id = l.id JOIN user u ON u.token = photo.owner WHERE l.min_lat >= ? AND l.max_lat <= ?")
+	args := []interface{}{south, north}
+	if west <= east {
+		query.WriteString(" AND l.min_lon >= ? AND l.max_lon <= ?")
+	} else {
+		query.WriteString(" AND (l.min_lon >= ? OR l.max_lon <= ?)")
+	}
+	args = append(args, west, east)
+
+	query.WriteString(" AND photo.published = 1 AND photo.owner NOT IN (SELECT banned FROM ban WHERE banning = ?) AND " + photoVisibleTo)
+	args = append(args, viewer)
+	args = append(args, visibleToArgs(viewer)...)
+
+	query.WriteString(" ORDER BY photo.created_at DESC LIMIT ?")
+	args = append(args, limit)
+
+	return db.queryPhotos(viewer, nil, query.String(), args...)
+}
+
+// GetUserMap returns the geotagged photos of the owner visible to the viewer, most recent first.
+func (db *appdbimpl) GetUserMap(viewer int64, owner int64) ([]Photo, error) {
+	args := append([]interface{}{owner}, visibleToArgs(viewer)...)
+	return db.queryPhotos(viewer, nil, "SELECT "+photoColumns+" FROM photo_location l JOIN photo ON photo.id = l.id JOIN user u ON u.token = photo.owner WHERE photo.owner=? AND photo.published = 1 AND "+photoVisibleTo+" ORDER BY photo.created_at DESC", args...)
+}
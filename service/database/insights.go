@@ -0,0 +1,125 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+)
+
+// dayFormat is the layout of the days in the statistics
+const dayFormat = "2006-01-02"
+
+// Kinds of the events recorded in follow_event
+const (
+	followEventFollow   = "follow"
+	followEventUnfollow = "unfollow"
+)
+
+// RecordView counts a view of the photo by the viewer. A viewer is counted once per photo per day.
+func (db *appdbimpl) RecordView(photoId int64, viewer int64) error {
+	day := globaltime.Now().UTC().Format(dayFormat)
+	return db.execQuery("INSERT OR IGNORE INTO photo_view (photo, viewer, day) VALUES (?, ?, ?)", photoId, viewer, day)
+}
+
+// insightsDays returns the days of the last `days` days, the oldest first and today last.
+func insightsDays(days int) []string {
+	today := globaltime.Now().UTC()
+	list := make([]string, days)
+	for i := range list {
+		list[i] = today.AddDate(0, 0, i-days+1).Format(dayFormat)
+	}
+	return list
+}
+
+// countByDay runs a query returning (day, count) rows and collects the counts by day.
+func (db *appdbimpl) countByDay(query string, args ...interface{}) (map[string]int64, error) {
+	rows, err := db.c.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{}
+	for rows.Next() {
+		var day string
+		var count int64
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+		counts[day] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetPhotoInsights returns the totals of views, likes and comments of the photo, with the daily counts of the last
+// `days` days. Only the ReactionLike reactions count as likes.
+func (db *appdbimpl) GetPhotoInsights(photoId int64, days int) (PhotoInsights, error) {
+	var insights PhotoInsights
+	err := db.c.QueryRow(`SELECT
+		(SELECT count(*) FROM photo_view WHERE photo = ?),
+		(SELECT count(DISTINCT viewer) FROM photo_view WHERE photo = ?),
+		(SELECT count(*) FROM likes WHERE photo = ? AND reaction = ?),
+		(SELECT count(*) FROM comment WHERE photo = ? AND deleted_at IS NULL)`, photoId, photoId, photoId, ReactionLike, photoId).
+		Scan(&insights.Views, &insights.UniqueViewers, &insights.Likes, &insights.Comments)
+	if err != nil {
+		return insights, err
+	}
+
+	dates := insightsDays(days)
+	since := dates[0]
+	views, err := db.countByDay("SELECT day, count(*) FROM photo_view WHERE photo = ? AND day >= ? GROUP BY day", photoId, since)
+	if err != nil {
+		return insights, err
+	}
+	likes, err := db.countByDay("SELECT date(created_at), count(*) FROM likes WHERE photo = ? AND reaction = ? AND date(created_at) >= ? GROUP BY date(created_at)", photoId, ReactionLike, since)
+	if err != nil {
+		return insights, err
+	}
+	comments, err := db.countByDay("SELECT date(created_at), count(*) FROM comment WHERE photo = ? AND deleted_at IS NULL AND date(created_at) >= ? GROUP BY date(created_at)", photoId, since)
+	if err != nil {
+		return insights, err
+	}
+
+	insights.Daily = make([]DailyPhotoStats, len(dates))
+	for i, day := range dates {
+		insights.Daily[i] = DailyPhotoStats{Date: day, Views: views[day], Likes: likes[day], Comments: comments[day]}
+	}
+	return insights, nil
+}
+
+// GetProfileInsights returns the number of followers of the user, with the followers gained and lost on each of the
+// last `days` days and the number of followers at the end of each day.
+func (db *appdbimpl) GetProfileInsights(token int64, days int) (ProfileInsights, error) {
+	var insights ProfileInsights
+	followers, err := db.getNumberFollowers(token)
+	if err != nil {
+		return insights, err
+	}
+	insights.Followers = followers
+
+	dates := insightsDays(days)
+	since := dates[0]
+	gained, err := db.countByDay("SELECT date(created_at), count(*) FROM follow_event WHERE followed = ? AND kind = ? AND date(created_at) >= ? GROUP BY date(created_at)", token, followEventFollow, since)
+	if err != nil {
+		return insights, err
+	}
+	lost, err := db.countByDay("SELECT date(created_at), count(*) FROM follow_event WHERE followed = ? AND kind = ? AND date(created_at) >= ? GROUP BY date(created_at)", token, followEventUnfollow, since)
+	if err != nil {
+		return insights, err
+	}
+
+	// Walk back from today, when the number of followers is known
+	insights.Daily = make([]DailyFollowerStats, len(dates))
+	for i := len(dates) - 1; i >= 0; i-- {
+		day := dates[i]
+		insights.Daily[i] = DailyFollowerStats{Date: day, Gained: gained[day], Lost: lost[day], Followers: followers}
+		followers -= gained[day] - lost[day]
+	}
+	return insights, nil
+}
+
+// recordFollowEvent keeps track of a follow or unfollow for the follower growth statistics.
+func recordFollowEvent(tx *sql.Tx, following int64, followed int64, kind string) error {
+	_, err := tx.Exec("INSERT INTO follow_event (following, followed, kind, created_at) VALUES (?, ?, ?, ?)",
+		following, followed, kind, formatTime(globaltime.Now()))
+	return err
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"database/sql"
+	"regexp"
+	"unicode/utf8"
+)
+
+// mentionPattern matches a `@` at the start of the text or after a character that cannot be part of a word or an
+// email address, followed by the characters allowed in usernames
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.-])@([a-zA-Z0-9_-]+)`)
+
+// The mentions are stored in one table per kind of text, keyed by the id of the text
+const (
+	photoMentions   = "photo_mention"
+	commentMentions = "comment_mention"
+)
+
+// mentionMatch is a `@username` found in a text, with its position in characters
+type mentionMatch struct {
+	username string
+	start    int
+	length   int
+}
+
+// findMentions returns the mentions in the text, in order of appearance. The names that are not valid usernames are
+// ignored.
+func findMentions(text string) []mentionMatch {
+	var mentions []mentionMatch
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		username := text[match[2]:match[3]]
+		if len(username) < 3 || len(username) > 16 {
+			continue
+		}
+
+		// The range includes the `@`, which is the byte before the username
+		mentions = append(mentions, mentionMatch{
+			username: username,
+			start:    utf8.RuneCountInString(text[:match[2]-1]),
+			length:   len(username) + 1,
+		})
+	}
+	return mentions
+}
+
+// setMentions replaces the mentions of a photo caption or a comment with the ones in its text, resolving them to the
+// users existing now. If kind is not empty, the users mentioned for the first time are notified with it, see
+// notifyMentions.
+func setMentions(tx *sql.Tx, table string, id int64, author int64, text string, kind string, photoId int64) error {
+	previous, err := mentionedUsers(tx, table, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM "+table+" WHERE id=?", id); err != nil {
+		return err
+	}
+
+	for _, mention := range findMentions(text) {
+		var user int64
+		err := tx.QueryRow("SELECT token FROM user WHERE username=?", mention.username).Scan(&user)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// The users who stay mentioned keep whether they have been notified
+		notified, mentioned := previous[user]
+		if _, err := tx.Exec("INSERT INTO "+table+" (id, user, start, length, notified) VALUES (?, ?, ?, ?, ?)", id, user, mention.start, mention.length, mentioned && notified); err != nil {
+			return err
+		}
+	}
+
+	if kind == "" {
+		return nil
+	}
+	return notifyMentions(tx, table, id, author, kind, photoId)
+}
+
+// notifyMentions notifies with kind the users mentioned in a photo caption or a comment who have not been notified
+// yet, provided the photo is published and listed to them and they did not ban the author. The others are notified by
+// a later call, for example when the photo is published.
+func notifyMentions(tx *sql.Tx, table string, id int64, author int64, kind string, photoId int64) error {
+	rows, err := tx.Query("SELECT DISTINCT user FROM "+table+" WHERE id=? AND notified = 0", id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var users []int64
+	for rows.Next() {
+		var user int64
+		if err := rows.Scan(&user); err != nil {
+			return err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, user := range users {
+		args := append([]interface{}{photoId, user, author}, listedToArgs(user)...)
+		var visible bool
+		err := tx.QueryRow(`SELECT count(*) > 0 FROM photo WHERE photo.id=?
+			AND ? NOT IN (SELECT banning FROM ban WHERE banned = ?) AND `+photoListedTo, args...).Scan(&visible)
+		if err != nil {
+			return err
+		}
+		if !visible {
+			continue
+		}
+
+		if err := addNotification(tx, user, author, kind, photoId); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE "+table+" SET notified = 1 WHERE id=? AND user=?", id, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyPhotoMentions notifies the users mentioned in the caption and in the visible comments of a photo who have not
+// been notified yet, to be called when the photo is published.
+func notifyPhotoMentions(tx *sql.Tx, photoId int64) error {
+	var owner int64
+	if err := tx.QueryRow("SELECT owner FROM photo WHERE id=?", photoId).Scan(&owner); err != nil {
+		return err
+	}
+	if err := notifyMentions(tx, photoMentions, photoId, owner, NotificationMention, photoId); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id, owner FROM comment WHERE photo=? AND deleted_at IS NULL AND hidden = 0", photoId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type comment struct{ id, author int64 }
+	var comments []comment
+	for rows.Next() {
+		var c comment
+		if err := rows.Scan(&c.id, &c.author); err != nil {
+			return err
+		}
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, c := range comments {
+		if err := notifyMentions(tx, commentMentions, c.id, c.author, NotificationCommentMention, photoId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mentionedUsers returns the users mentioned in a photo caption or a comment, and whether they have been notified.
+func mentionedUsers(tx *sql.Tx, table string, id int64) (map[int64]bool, error) {
+	rows, err := tx.Query("SELECT user, max(notified) FROM "+table+" WHERE id=? GROUP BY user", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := map[int64]bool{}
+	for rows.Next() {
+		var user int64
+		var notified bool
+		if err := rows.Scan(&user, &notified); err != nil {
+			return nil, err
+		}
+		users[user] = notified
+	}
+	return users, rows.Err()
+}
+
+// getMentions returns the mentions of a photo caption or a comment, with the current usernames of the mentioned users.
+func (db *appdbimpl) getMentions(table string, id int64) ([]Mention, error) {
+	rows, err := db.c.Query("SELECT m.start, m.length, m.user, u.username FROM "+table+" m JOIN user u ON u.token = m.user WHERE m.id=? ORDER BY m.start", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mentions []Mention
+	for rows.Next() {
+		var mention Mention
+		if err := rows.Scan(&mention.Start, &mention.Length, &mention.UserId, &mention.Username); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions, rows.Err()
+}
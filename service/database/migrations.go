@@ -0,0 +1,341 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are the changes to the database structure made after the first version, in order. The version of a
+// database, stored in `PRAGMA user_version`, is the number of migrations already applied to it. New changes must be
+// made both in checkAndCreateTables, which creates the latest structure, and by a new migration appended here.
+var migrations = []string{
+	// Blocklist of uploaded images
+	`CREATE TABLE IF NOT EXISTS blocked_upload (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		match_type TEXT NOT NULL,
+		hash       TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+	);`,
+
+	// Audience of the photos and close friends
+	`ALTER TABLE photo ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+		CHECK (visibility IN ('public', 'followers', 'close_friends', 'only_me'));
+
+	CREATE TABLE IF NOT EXISTS close_friend (
+		owner  INTEGER NOT NULL REFERENCES user,
+		friend INTEGER NOT NULL REFERENCES user,
+		PRIMARY KEY (owner, friend),
+		CHECK (owner != friend)
+	);`,
+
+	// Trash
+	`ALTER TABLE photo ADD COLUMN deleted_at DATETIME;`,
+
+	// Drafts and scheduled photos
+	`ALTER TABLE photo ADD COLUMN published INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE photo ADD COLUMN publish_at DATETIME;`,
+
+	// Non-destructive edits
+	`ALTER TABLE photo ADD COLUMN edit_recipe TEXT;`,
+
+	// Watermarks
+	`CREATE TABLE IF NOT EXISTS watermark (
+		owner    INTEGER PRIMARY KEY REFERENCES user ON DELETE CASCADE,
+		kind     TEXT NOT NULL CHECK (kind IN ('username', 'image')),
+		position TEXT NOT NULL,
+		opacity  REAL NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS watermark_image (
+		owner INTEGER PRIMARY KEY REFERENCES user ON DELETE CASCADE,
+		img   BLOB NOT NULL
+	);`,
+
+	// Animated GIFs
+	`ALTER TABLE photo ADD COLUMN is_animated INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE photo ADD COLUMN frame_count INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE photo ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE photo ADD COLUMN poster BLOB;`,
+
+	// Geotags
+	`ALTER TABLE photo ADD COLUMN latitude REAL;
+	ALTER TABLE photo ADD COLUMN longitude REAL;
+	ALTER TABLE photo ADD COLUMN place_name TEXT;
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS photo_location USING rtree (
+		id,
+		min_lat, max_lat,
+		min_lon, max_lon
+	);`,
+
+	// Captions and hashtags
+	`ALTER TABLE photo ADD COLUMN caption TEXT NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS tag (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		name        TEXT NOT NULL UNIQUE,
+		usage_count INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS photo_tag (
+		photo INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		tag   INTEGER NOT NULL REFERENCES tag ON DELETE CASCADE,
+		PRIMARY KEY (photo, tag)
+	);
+
+	CREATE INDEX IF NOT EXISTS photo_tag_by_tag ON photo_tag (tag, photo);`,
+
+	// People tags and notifications
+	`ALTER TABLE user ADD COLUMN auto_approve_tags INTEGER NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS person_tag (
+		photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		user       INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		tagged_by  INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		x          REAL NOT NULL CHECK (x BETWEEN 0 AND 1),
+		y          REAL NOT NULL CHECK (y BETWEEN 0 AND 1),
+		status     TEXT NOT NULL CHECK (status IN ('pending', 'approved')),
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (photo, user)
+	);
+
+	CREATE INDEX IF NOT EXISTS person_tag_by_user ON person_tag (user, status);
+
+	CREATE TABLE IF NOT EXISTS notification (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		recipient  INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		actor      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		kind       TEXT NOT NULL,
+		photo      INTEGER REFERENCES photo ON DELETE CASCADE,
+		created_at DATETIME NOT NULL,
+		read       INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS notification_by_recipient ON notification (recipient, id);`,
+
+	// Insights. SQLite cannot add a column defaulting to the current time, so the likes are copied to a new table.
+	// The existing likes are dated at the migration.
+	`CREATE TABLE likes_new (
+		owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+		PRIMARY KEY (owner, photo)
+	);
+	INSERT INTO likes_new (owner, photo) SELECT owner, photo FROM likes;
+	DROP TABLE likes;
+	ALTER TABLE likes_new RENAME TO likes;
+
+	CREATE TABLE IF NOT EXISTS photo_view (
+		photo  INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		viewer INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		day    TEXT NOT NULL,
+		PRIMARY KEY (photo, viewer, day)
+	);
+
+	CREATE TABLE IF NOT EXISTS follow_event (
+		following  INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		followed   INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		kind       TEXT NOT NULL CHECK (kind IN ('follow', 'unfollow')),
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS follow_event_by_followed ON follow_event (followed, created_at);`,
+
+	// Pinned photos
+	`ALTER TABLE photo ADD COLUMN pinned_at DATETIME;`,
+
+	// Albums
+	`CREATE TABLE IF NOT EXISTS album (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner       INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		title       TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		visibility  TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'members')),
+		cover       INTEGER REFERENCES photo ON DELETE SET NULL,
+		created_at  DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS album_member (
+		album INTEGER NOT NULL REFERENCES album ON DELETE CASCADE,
+		user  INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		role  TEXT NOT NULL CHECK (role IN ('contributor', 'viewer')),
+		PRIMARY KEY (album, user)
+	);
+
+	CREATE TABLE IF NOT EXISTS album_photo (
+		album    INTEGER NOT NULL REFERENCES album ON DELETE CASCADE,
+		photo    INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		added_by INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		added_at DATETIME NOT NULL,
+		PRIMARY KEY (album, photo)
+	);`,
+
+	// Saved photos and collections
+	`CREATE TABLE IF NOT EXISTS saved (
+		owner    INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		photo    INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		saved_at DATETIME NOT NULL,
+		PRIMARY KEY (owner, photo)
+	);
+
+	CREATE TABLE IF NOT EXISTS collection (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		name       TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE (owner, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS collection_photo (
+		collection INTEGER NOT NULL REFERENCES collection ON DELETE CASCADE,
+		photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		added_at   DATETIME NOT NULL,
+		PRIMARY KEY (collection, photo)
+	);`,
+
+	// Stories
+	`ALTER TABLE user ADD COLUMN archive_stories INTEGER NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS story (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		img        BLOB NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		archived   INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS story_by_owner ON story (owner, expires_at);
+
+	CREATE TABLE IF NOT EXISTS story_view (
+		story     INTEGER NOT NULL REFERENCES story ON DELETE CASCADE,
+		viewer    INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		viewed_at DATETIME NOT NULL,
+		PRIMARY KEY (story, viewer)
+	);`,
+
+	// Reactions
+	`ALTER TABLE likes ADD COLUMN reaction TEXT NOT NULL DEFAULT '❤️';`,
+
+	// Reposts
+	`CREATE TABLE IF NOT EXISTS repost (
+		owner      INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (owner, photo)
+	);
+
+	CREATE INDEX IF NOT EXISTS repost_by_photo ON repost (photo, created_at);`,
+
+	// Post edits
+	`ALTER TABLE photo ADD COLUMN edited_at DATETIME;
+
+	CREATE TABLE IF NOT EXISTS photo_revision (
+		photo      INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		revision   INTEGER NOT NULL,
+		caption    TEXT NOT NULL,
+		latitude   REAL,
+		longitude  REAL,
+		place_name TEXT,
+		img        BLOB,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (photo, revision)
+	);`,
+
+	// Comment replies
+	`ALTER TABLE comment ADD COLUMN parent_id INTEGER REFERENCES comment;
+	ALTER TABLE comment ADD COLUMN deleted_at DATETIME;
+
+	CREATE INDEX IF NOT EXISTS comment_by_parent ON comment (parent_id, created_at);`,
+
+	// Comment edits
+	`ALTER TABLE comment ADD COLUMN edited_at DATETIME;
+
+	CREATE TABLE IF NOT EXISTS comment_revision (
+		comment    INTEGER NOT NULL REFERENCES comment ON DELETE CASCADE,
+		revision   INTEGER NOT NULL,
+		content    TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (comment, revision)
+	);`,
+
+	// Comment likes
+	`CREATE TABLE IF NOT EXISTS comment_like (
+		comment    INTEGER NOT NULL REFERENCES comment ON DELETE CASCADE,
+		owner      INTEGER NOT NULL REFERENCES user,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (comment, owner)
+	);`,
+
+	// Mentions
+	`CREATE TABLE IF NOT EXISTS photo_mention (
+		id     INTEGER NOT NULL REFERENCES photo ON DELETE CASCADE,
+		user   INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		start  INTEGER NOT NULL,
+		length INTEGER NOT NULL,
+		PRIMARY KEY (id, start)
+	);
+
+	CREATE TABLE IF NOT EXISTS comment_mention (
+		id     INTEGER NOT NULL REFERENCES comment ON DELETE CASCADE,
+		user   INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		start  INTEGER NOT NULL,
+		length INTEGER NOT NULL,
+		PRIMARY KEY (id, start)
+	);`,
+
+	// Comment moderation
+	`ALTER TABLE photo ADD COLUMN comments TEXT NOT NULL DEFAULT 'everyone' CHECK (comments IN ('everyone', 'followers', 'off'));
+	ALTER TABLE comment ADD COLUMN hidden INTEGER NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS comment_filter (
+		user    INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+		keyword TEXT NOT NULL,
+		PRIMARY KEY (user, keyword)
+	);`,
+
+	// Mention notifications, the mentions already stored have been notified unless they are in a hidden comment
+	`ALTER TABLE photo_mention ADD COLUMN notified INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE comment_mention ADD COLUMN notified INTEGER NOT NULL DEFAULT 0;
+
+	UPDATE photo_mention SET notified = 1;
+	UPDATE comment_mention SET notified = 1 WHERE id IN (SELECT id FROM comment WHERE hidden = 0);`,
+}
+
+// migrate applies the migrations not yet applied to the database, each in its own transaction along with the update
+// of the version, so that a failed migration can be retried at the next start.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("error reading the database version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("the database version %d is newer than the supported one %d", version, len(migrations))
+	}
+
+	for ; version < len(migrations); version++ {
+		if err := applyMigration(db, version); err != nil {
+			return fmt.Errorf("error migrating the database to version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration applies the migration that brings the database from the given version to the next one.
+func applyMigration(db *sql.DB, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(migrations[version]); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
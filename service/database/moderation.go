@@ -0,0 +1,25 @@
+package database
+
+// AddBlockedUpload records an upload rejected because the image matched the blocklist.
+func (db *appdbimpl) AddBlockedUpload(token int64, matchType string, hash string) error {
+	return db.execQuery("INSERT INTO blocked_upload (owner, match_type, hash) VALUES (?, ?, ?)", token, matchType, hash)
+}
+
+// GetBlockedUploads returns the rejected uploads, most recent first.
+func (db *appdbimpl) GetBlockedUploads() ([]BlockedUpload, error) {
+	rows, err := db.c.Query("SELECT b.id, b.owner, u.username, b.match_type, b.hash, b.created_at FROM blocked_upload b JOIN user u ON u.token = b.owner ORDER BY b.created_at DESC, b.id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []BlockedUpload
+	for rows.Next() {
+		var upload BlockedUpload
+		if err := rows.Scan(&upload.Id, &upload.Owner, &upload.OwnerUsername, &upload.MatchType, &upload.Hash, &upload.CreatedAt); err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, rows.Err()
+}
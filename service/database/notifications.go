@@ -0,0 +1,52 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+)
+
+// Notification kinds
+const (
+	NotificationPhotoTag       = "photo_tag"
+	NotificationRepost         = "repost"
+	NotificationMention        = "mention"
+	NotificationCommentMention = "comment_mention"
+)
+
+// addNotification notifies the recipient of an action of the actor on a photo. Users are not notified of their own
+// actions.
+func addNotification(tx *sql.Tx, recipient int64, actor int64, kind string, photoId int64) error {
+	if recipient == actor {
+		return nil
+	}
+	_, err := tx.Exec("INSERT INTO notification (recipient, actor, kind, photo, created_at) VALUES (?, ?, ?, ?, ?)",
+		recipient, actor, kind, photoId, formatTime(globaltime.Now()))
+	return err
+}
+
+// GetNotifications returns the most recent notifications of the user, skipping the ones from users they banned.
+func (db *appdbimpl) GetNotifications(token int64, limit int) ([]Notification, error) {
+	rows, err := db.c.Query(`SELECT n.id, n.kind, u.username, n.photo, n.created_at, n.read FROM notification n
+		JOIN user u ON u.token = n.actor
+		WHERE n.recipient=? AND n.actor NOT IN (SELECT banned FROM ban WHERE banning = ?)
+		ORDER BY n.id DESC LIMIT ?`, token, token, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		var notification Notification
+		if err := rows.Scan(&notification.Id, &notification.Kind, &notification.Actor, &notification.Photo, &notification.CreatedAt, &notification.Read); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkNotificationsRead marks all the notifications of the user as read.
+func (db *appdbimpl) MarkNotificationsRead(token int64) error {
+	return db.execQuery("UPDATE notification SET read=1 WHERE recipient=? AND read=0", token)
+}
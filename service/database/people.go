@@ -0,0 +1,118 @@
+package database
+
+import "WasaPhoto/service/globaltime"
+
+// Status of the tags of people in photos
+const (
+	PersonTagPending  = "pending"
+	PersonTagApproved = "approved"
+)
+
+// TagPerson tags the user in the photo at the given position, or moves the tag if the user is already tagged. New tags
+// notify the tagged user and are pending until approved, unless the user approves the tags automatically or tagged
+// themselves. It returns the status of the tag and whether it is new.
+func (db *appdbimpl) TagPerson(photoId int64, taggedBy int64, user int64, x float64, y float64) (string, bool, error) {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return "", false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM person_tag WHERE photo=? AND user=?", photoId, user).Scan(&status)
+	if err == nil {
+		if _, err := tx.Exec("UPDATE person_tag SET x=?, y=? WHERE photo=? AND user=?", x, y, photoId, user); err != nil {
+			return "", false, err
+		}
+		return status, false, tx.Commit()
+	}
+
+	var autoApprove bool
+	if err := tx.QueryRow("SELECT auto_approve_tags FROM user WHERE token=?", user).Scan(&autoApprove); err != nil {
+		return "", false, err
+	}
+	status = PersonTagPending
+	if autoApprove || user == taggedBy {
+		status = PersonTagApproved
+	}
+
+	_, err = tx.Exec("INSERT INTO person_tag (photo, user, tagged_by, x, y, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		photoId, user, taggedBy, x, y, status, formatTime(globaltime.Now()))
+	if err != nil {
+		return "", false, err
+	}
+	if err := addNotification(tx, user, taggedBy, NotificationPhotoTag, photoId); err != nil {
+		return "", false, err
+	}
+	return status, true, tx.Commit()
+}
+
+// CheckPersonTag checks if the user is tagged in the photo, either pending or approved.
+func (db *appdbimpl) CheckPersonTag(photoId int64, user int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM person_tag WHERE photo=? AND user=?", photoId, user)
+}
+
+// CheckPendingPersonTag checks if the user has a pending tag in the photo.
+func (db *appdbimpl) CheckPendingPersonTag(photoId int64, user int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM person_tag WHERE photo=? AND user=? AND status=?", photoId, user, PersonTagPending)
+}
+
+// ApprovePersonTag approves a pending tag of the user in the photo.
+func (db *appdbimpl) ApprovePersonTag(photoId int64, user int64) error {
+	return db.execQuery("UPDATE person_tag SET status=? WHERE photo=? AND user=?", PersonTagApproved, photoId, user)
+}
+
+// RemovePersonTag removes the tag of the user from the photo.
+func (db *appdbimpl) RemovePersonTag(photoId int64, user int64) error {
+	return db.execQuery("DELETE FROM person_tag WHERE photo=? AND user=?", photoId, user)
+}
+
+// GetPhotoPeople returns the people tagged in the photo as seen by the viewer: approved tags are visible to everyone,
+// pending tags only to the owner of the photo and the tagged user. Users who banned the viewer are left out.
+func (db *appdbimpl) GetPhotoPeople(photoId int64, viewer int64) ([]PersonTag, error) {
+	rows, err := db.c.Query(`SELECT u.username, pt.x, pt.y, pt.status, pt.created_at FROM person_tag pt
+		JOIN user u ON u.token = pt.user
+		JOIN photo ON photo.id = pt.photo
+		WHERE pt.photo=? AND (pt.status=? OR pt.user=? OR photo.owner=?)
+		AND pt.user NOT IN (SELECT banning FROM ban WHERE banned = ?)
+		ORDER BY pt.created_at`, photoId, PersonTagApproved, viewer, viewer, viewer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var people []PersonTag
+	for rows.Next() {
+		var person PersonTag
+		if err := rows.Scan(&person.Username, &person.X, &person.Y, &person.Status, &person.CreatedAt); err != nil {
+			return nil, err
+		}
+		people = append(people, person)
+	}
+	return people, rows.Err()
+}
+
+// GetPendingTaggedPhotos returns the photos where the user has been tagged and has not approved the tag yet.
+func (db *appdbimpl) GetPendingTaggedPhotos(token int64) ([]Photo, error) {
+	args := append([]interface{}{token, PersonTagPending}, visibleToArgs(token)...)
+	return db.queryPhotos(token, nil, "SELECT "+photoColumns+" FROM person_tag pt JOIN photo ON photo.id = pt.photo JOIN user u ON u.token = photo.owner WHERE pt.user=? AND pt.status=? AND photo.published = 1 AND "+photoVisibleTo+" ORDER BY pt.created_at DESC", args...)
+}
+
+// GetPhotosOf returns the photos where the user has an approved tag visible to the viewer, most recent first, skipping
+// the photos of the users banned by the viewer.
+func (db *appdbimpl) GetPhotosOf(viewer int64, user int64) ([]Photo, error) {
+	args := append([]interface{}{user, PersonTagApproved, viewer}, visibleToArgs(viewer)...)
+	return db.queryPhotos(viewer, nil, "SELECT "+photoColumns+" FROM person_tag pt JOIN photo ON photo.id = pt.photo JOIN user u ON u.token = photo.owner WHERE pt.user=? AND pt.status=? AND photo.published = 1 AND photo.owner NOT IN (SELECT banned FROM ban WHERE banning = ?) AND "+photoVisibleTo+" ORDER BY photo.created_at DESC", args...)
+}
+
+// GetAutoApproveTags returns whether the tags of the user in photos are approved without asking.
+func (db *appdbimpl) GetAutoApproveTags(token int64) (bool, error) {
+	var autoApprove bool
+	err := db.c.QueryRow("SELECT auto_approve_tags FROM user WHERE token=?", token).Scan(&autoApprove)
+	return autoApprove, err
+}
+
+// SetAutoApproveTags sets whether the tags of the user in photos are approved without asking.
+func (db *appdbimpl) SetAutoApproveTags(token int64, autoApprove bool) error {
+	return db.execQuery("UPDATE user SET auto_approve_tags=? WHERE token=?", autoApprove, token)
+}
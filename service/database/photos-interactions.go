@@ -1,5 +1,13 @@
 package database
 
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+)
+
 // Helper function to execute a query that doesn't return rows
 func (db *appdbimpl) execQuery(query string, args ...interface{}) error {
 	_, err := db.c.Exec(query, args...)
@@ -16,13 +24,210 @@ func (db *appdbimpl) checkExistence(query string, args ...interface{}) (bool, er
 	return count == 1, nil
 }
 
+// photoColumns are the columns of `photo` read by queryPhotos, with the owner joined as `u`. The counts and the flags
+// of the viewer are read by correlated subqueries, whose placeholders are bound by queryPhotos.
+const photoColumns = `photo.id, photo.owner, u.username, photo.created_at, photo.visibility, photo.is_animated,
+	photo.frame_count, photo.duration_ms, photo.latitude, photo.longitude, photo.place_name, photo.caption,
+	photo.pinned_at IS NOT NULL, photo.edited_at, photo.comments,
+	(SELECT count(*) FROM likes lk WHERE lk.photo = photo.id AND lk.reaction = ?),
+	(SELECT count(*) FROM comment cm WHERE cm.photo = photo.id AND cm.deleted_at IS NULL AND cm.hidden = 0),
+	EXISTS (SELECT 1 FROM likes lk WHERE lk.photo = photo.id AND lk.owner = ? AND lk.reaction = ?),
+	EXISTS (SELECT 1 FROM saved sv WHERE sv.photo = photo.id AND sv.owner = ?),
+	COALESCE((SELECT lk.reaction FROM likes lk WHERE lk.photo = photo.id AND lk.owner = ?), '')`
+
+// photoDetailsBatch is the maximum number of photos whose reactions and mentions are read by a single query
+const photoDetailsBatch = 500
+
+// queryPhotos runs a query selecting photoColumns followed by the columns scanned into the destinations returned by
+// extra, if not nil. It returns the photos along with likes, reactions, comments, mentions, and whether the viewer
+// liked and saved them.
+func (db *appdbimpl) queryPhotos(viewer int64, extra func(*Photo) []interface{}, query string, args ...interface{}) ([]Photo, error) {
+	columnsArgs := []interface{}{ReactionLike, viewer, ReactionLike, viewer, viewer}
+	rows, err := db.c.Query(query, append(columnsArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var photos []Photo
+	for rows.Next() {
+		var photo Photo
+		var latitude, longitude sql.NullFloat64
+		var placeName, editedAt sql.NullString
+		dest := []interface{}{&photo.Id, &photo.Owner, &photo.OwnerUsername, &photo.CreatedAt, &photo.Visibility,
+			&photo.IsAnimated, &photo.FrameCount, &photo.DurationMs, &latitude, &longitude, &placeName, &photo.Caption,
+			&photo.Pinned, &editedAt, &photo.CommentsMode, &photo.NumberOfLikes, &photo.NumberOfComments,
+			&photo.IsLiked, &photo.IsSaved, &photo.MyReaction}
+		if extra != nil {
+			dest = append(dest, extra(&photo)...)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if latitude.Valid && longitude.Valid {
+			photo.Location = &Location{Latitude: latitude.Float64, Longitude: longitude.Float64, Name: placeName.String}
+		}
+		photo.Edited, photo.EditedAt = editedAt.Valid, editedAt.String
+		photo.Reactions = make(map[string]int64)
+		photos = append(photos, photo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for start := 0; start < len(photos); start += photoDetailsBatch {
+		end := start + photoDetailsBatch
+		if end > len(photos) {
+			end = len(photos)
+		}
+		if err := db.addPhotoDetails(photos[start:end]); err != nil {
+			return nil, err
+		}
+	}
+	return photos, nil
+}
+
+// addPhotoDetails reads the reaction counts and the mentions of the photos, which do not fit in a column, with one
+// query each for all the photos.
+func (db *appdbimpl) addPhotoDetails(photos []Photo) error {
+	byId := make(map[int64]*Photo, len(photos))
+	ids := make([]interface{}, len(photos))
+	for i := range photos {
+		byId[photos[i].Id] = &photos[i]
+		ids[i] = photos[i].Id
+	}
+	in := "(?" + strings.Repeat(", ?", len(ids)-1) + ")"
+
+	rows, err := db.c.Query("SELECT photo, reaction, count(*) FROM likes WHERE photo IN "+in+" GROUP BY photo, reaction", ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var photoId, count int64
+		var reaction string
+		if err := rows.Scan(&photoId, &reaction, &count); err != nil {
+			return err
+		}
+		byId[photoId].Reactions[reaction] = count
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = db.c.Query("SELECT m.id, m.start, m.length, m.user, u.username FROM "+photoMentions+" m JOIN user u ON u.token = m.user WHERE m.id IN "+in+" ORDER BY m.id, m.start", ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var photoId int64
+		var mention Mention
+		if err := rows.Scan(&photoId, &mention.Start, &mention.Length, &mention.UserId, &mention.Username); err != nil {
+			return err
+		}
+		photo := byId[photoId]
+		photo.Mentions = append(photo.Mentions, mention)
+	}
+	return rows.Err()
+}
+
+// PhotoOptions are the settings chosen by the owner when posting a photo
+type PhotoOptions struct {
+	// Visibility is the audience of the photo
+	Visibility string
+
+	// Caption is the text accompanying the photo, its hashtags are indexed
+	Caption string
+
+	// Draft keeps the photo unpublished until the owner publishes it
+	Draft bool
+
+	// PublishAt, if not zero, is when the photo will be published by the scheduler
+	PublishAt time.Time
+
+	// Animation is filled in by the server for animated GIFs, nil for still images
+	Animation *PhotoAnimation
+
+	// Location is the place where the photo was taken, nil if not given
+	Location *PhotoLocation
+}
+
+// PhotoAnimation is the data recorded for animated GIFs
+type PhotoAnimation struct {
+	FrameCount int
+	Duration   time.Duration
+
+	// Poster is the first frame, used as a still preview
+	Poster []byte
+}
+
 // Posting and Deleting Photos
-func (db *appdbimpl) PostPhoto(image []byte, token int64) error {
-	return db.execQuery("INSERT INTO photo (owner, img) VALUES (?, ?)", token, image)
+func (db *appdbimpl) PostPhoto(image []byte, token int64, options PhotoOptions) error {
+	published := !options.Draft && options.PublishAt.IsZero()
+	var publishAt interface{}
+	if !options.PublishAt.IsZero() {
+		publishAt = formatTime(options.PublishAt)
+	}
+	animated, frameCount, durationMs, poster := false, 1, int64(0), []byte(nil)
+	if options.Animation != nil {
+		animated, frameCount, durationMs, poster = true, options.Animation.FrameCount, options.Animation.Duration.Milliseconds(), options.Animation.Poster
+	}
+
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec("INSERT INTO photo (owner, img, visibility, published, publish_at, is_animated, frame_count, duration_ms, poster, caption, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		token, image, options.Visibility, published, publishAt, animated, frameCount, durationMs, poster, options.Caption, formatTime(globaltime.Now()))
+	if err != nil {
+		return err
+	}
+	photoId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if options.Location != nil {
+		if err := setLocation(tx, photoId, *options.Location); err != nil {
+			return err
+		}
+	}
+	if err := setPhotoTags(tx, photoId, options.Caption); err != nil {
+		return err
+	}
+	if err := setMentions(tx, photoMentions, photoId, token, options.Caption, NotificationMention, photoId); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
+// DeletePhoto moves the photo to the trash of its owner, see RestorePhoto and PurgeTrash. The photo is unpinned, no
+// longer counts in the usage of its hashtags, and its reposts are removed.
 func (db *appdbimpl) DeletePhoto(token int64, photoId int64) error {
-	return db.execQuery("DELETE FROM photo WHERE owner=? AND id=?", token, photoId)
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec("UPDATE photo SET deleted_at=?, pinned_at=NULL WHERE owner=? AND id=? AND deleted_at IS NULL", formatTime(globaltime.Now()), token, photoId)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil || deleted == 0 {
+		return err
+	}
+	if err := setPhotoTags(tx, photoId, ""); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM repost WHERE photo=?", photoId); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Retrieving Photo Data
@@ -32,6 +237,13 @@ func (db *appdbimpl) GetImage(photoId int64) ([]byte, error) {
 	return image, err
 }
 
+// GetPoster returns the still preview of an animated photo, or nil for still photos
+func (db *appdbimpl) GetPoster(photoId int64) ([]byte, error) {
+	var poster []byte
+	err := db.c.QueryRow("SELECT poster FROM photo WHERE id=?", photoId).Scan(&poster)
+	return poster, err
+}
+
 func (db *appdbimpl) GetPhotoOwner(photoId int64) (int64, error) {
 	var owner int64
 	err := db.c.QueryRow("SELECT owner FROM photo WHERE id=?", photoId).Scan(&owner)
@@ -44,119 +256,205 @@ func (db *appdbimpl) CheckPhotoOwner(token int64, photoId int64) (bool, error) {
 
 // Liking and Unliking Photos
 func (db *appdbimpl) LikePhoto(token int64, photoId int64) error {
-	return db.execQuery("INSERT INTO like (owner, photo) VALUES (?, ?)", token, photoId)
+	return db.SetReaction(token, photoId, ReactionLike)
 }
 
 func (db *appdbimpl) UnlikePhoto(token int64, photoId int64) error {
-	return db.execQuery("DELETE FROM like WHERE owner=? AND photo=?", token, photoId)
+	return db.execQuery("DELETE FROM likes WHERE owner=? AND photo=? AND reaction=?", token, photoId, ReactionLike)
 }
 
 func (db *appdbimpl) CheckLike(token int64, photoId int64) (bool, error) {
-	return db.checkExistence("SELECT count(*) FROM like WHERE owner=? AND photo=?", token, photoId)
+	return db.checkExistence("SELECT count(*) FROM likes WHERE owner=? AND photo=? AND reaction=?", token, photoId, ReactionLike)
+}
+
+// GetLikers returns the users who liked the photo with ReactionLike, the most recent first, skipping the first offset
+// and returning at most limit users. The users who banned the viewer or are banned by them are left out, and the
+// likers followed by the viewer are marked.
+func (db *appdbimpl) GetLikers(photoId int64, viewer int64, offset int, limit int) ([]Liker, error) {
+	rows, err := db.c.Query(`SELECT u.username, l.created_at, l.owner IN (SELECT followed FROM follow WHERE following = ?)
+		FROM likes l JOIN user u ON u.token = l.owner
+		WHERE l.photo = ? AND l.reaction = ? AND l.owner NOT IN (SELECT banning FROM ban WHERE banned = ?) AND l.owner NOT IN (SELECT banned FROM ban WHERE banning = ?)
+		ORDER BY l.created_at DESC, u.username LIMIT ? OFFSET ?`, viewer, photoId, ReactionLike, viewer, viewer, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var likers []Liker
+	for rows.Next() {
+		var liker Liker
+		if err := rows.Scan(&liker.Username, &liker.LikedAt, &liker.IsFollowed); err != nil {
+			return nil, err
+		}
+		likers = append(likers, liker)
+	}
+	return likers, rows.Err()
 }
 
 // Commenting on Photos
-func (db *appdbimpl) CommentPhoto(token int64, photoId int64, content string) (int64, error) {
-	res, err := db.c.Exec("INSERT INTO comment (owner, content, photo) VALUES (?, ?, ?)", token, content, photoId)
+func (db *appdbimpl) CommentPhoto(token int64, photoId int64, parentId *int64, content string) (int64, error) {
+	tx, err := db.c.Begin()
 	if err != nil {
 		return -1, err
 	}
-	return res.LastInsertId()
-}
+	defer func() { _ = tx.Rollback() }()
 
-func (db *appdbimpl) GetPhotoComments(photoId int64) ([]FullDataComment, error) {
-	rows, err := db.c.Query("SELECT id, content, created_at, u.username, photo FROM comment JOIN user u ON u.token = comment.owner WHERE photo=? ORDER BY created_at DESC", photoId)
+	res, err := tx.Exec("INSERT INTO comment (owner, content, photo, parent_id, created_at) VALUES (?, ?, ?, ?, ?)",
+		token, content, photoId, parentId, formatTime(globaltime.Now()))
 	if err != nil {
-		return nil, err
+		return -1, err
 	}
-	defer rows.Close()
+	commentId, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	if err := setCommentMentions(tx, commentId); err != nil {
+		return -1, err
+	}
+	return commentId, tx.Commit()
+}
 
-	var comments []FullDataComment
-	for rows.Next() {
-		var comment FullDataComment
-		if err := rows.Scan(&comment.Id, &comment.Content, &comment.CreatedAt, &comment.Owner, &comment.Photo); err != nil {
-			return nil, err
+// GetPhotoComments returns a page of at most limit top-level comments of the photo in the given order, with the number
+// of their replies, which are returned by GetCommentReplies. The page starts after the cursor, if not empty, and its
+// Next cursor is empty on the last page. It returns ErrInvalidCursor if the cursor cannot be parsed.
+func (db *appdbimpl) GetPhotoComments(photoId int64, viewer int64, sort string, cursor string, limit int) (CommentsPage, error) {
+	// The comments are ordered by time and id, preceded by the likes for CommentSortTop
+	order, after := "DESC", "<"
+	if sort == CommentSortOldest {
+		order, after = "ASC", ">"
+	}
+
+	query := "SELECT " + commentColumns + " FROM comment JOIN user u ON u.token = comment.owner WHERE comment.photo=? AND comment.parent_id IS NULL AND " + commentVisibleTo
+	args := []interface{}{photoId, viewer}
+	if cursor != "" {
+		position, err := decodeCommentCursor(cursor)
+		if err != nil {
+			return CommentsPage{}, err
+		}
+		afterTime := "(comment.created_at " + after + " ? OR (comment.created_at = ? AND comment.id " + after + " ?))"
+		if sort == CommentSortTop {
+			query += " AND (" + commentLikes + " < ? OR (" + commentLikes + " = ? AND " + afterTime + "))"
+			args = append(args, position.likes, position.likes)
+		} else {
+			query += " AND " + afterTime
 		}
-		comments = append(comments, comment)
+		args = append(args, formatTime(position.createdAt), formatTime(position.createdAt), position.id)
 	}
 
-	if rows.Err() != nil {
-		return comments, rows.Err()
+	query += " ORDER BY "
+	if sort == CommentSortTop {
+		query += commentLikes + " DESC, "
 	}
+	query += "comment.created_at " + order + ", comment.id " + order + " LIMIT ?"
 
-	return comments, nil
+	// One more comment is loaded to know if there is a next page
+	comments, err := db.queryComments(viewer, query, append(args, limit+1)...)
+	if err != nil {
+		return CommentsPage{}, err
+	}
+	page := CommentsPage{Comments: comments}
+	if len(comments) > limit {
+		page.Comments = comments[:limit]
+		last := page.Comments[limit-1]
+		createdAt, err := time.Parse(time.RFC3339, last.CreatedAt)
+		if err != nil {
+			return CommentsPage{}, err
+		}
+		page.Next = commentCursor{likes: last.NumberOfLikes, createdAt: createdAt, id: last.Id}.encode()
+	}
+	return page, nil
 }
 
+// GetCommentOwner returns the author of the comment. Tombstones have no author.
 func (db *appdbimpl) GetCommentOwner(commentId int64) (int64, error) {
 	var owner int64
-	err := db.c.QueryRow("SELECT owner FROM comment WHERE id=?", commentId).Scan(&owner)
+	err := db.c.QueryRow("SELECT owner FROM comment WHERE id=? AND deleted_at IS NULL", commentId).Scan(&owner)
 	return owner, err
 }
 
-func (db *appdbimpl) GetComment(commentId int64) (FullDataComment, error) {
-	var comment FullDataComment
-	err := db.c.QueryRow("SELECT * FROM comment WHERE id=?", commentId).Scan(&comment.Id, &comment.Content, &comment.CreatedAt, &comment.Owner, &comment.Photo)
-	return comment, err
+func (db *appdbimpl) GetComment(commentId int64, viewer int64) (FullDataComment, error) {
+	comments, err := db.queryComments(viewer, "SELECT "+commentColumns+" FROM comment JOIN user u ON u.token = comment.owner WHERE comment.id=? AND "+commentVisibleTo, commentId, viewer)
+	if err != nil {
+		return FullDataComment{}, err
+	}
+	if len(comments) == 0 {
+		return FullDataComment{}, sql.ErrNoRows
+	}
+	return comments[0], nil
 }
 
+// DeleteComment deletes the comment, leaving a tombstone in its place if it has replies.
 func (db *appdbimpl) DeleteComment(commentId int64) error {
-	return db.execQuery("DELETE FROM comment WHERE id=?", commentId)
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := removeComment(tx, commentId); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Stream (Fetching Photos for the User's Stream)
 func (db *appdbimpl) GetMyStream(token int64) ([]Photo, error) {
-	rows, err := db.c.Query("SELECT id, owner, u.username, created_at FROM photo JOIN user u ON u.token = photo.owner WHERE owner NOT IN (SELECT banning FROM ban WHERE banned=?) AND owner != ? AND owner IN (SELECT followed FROM follow WHERE following=?) ORDER BY created_at DESC", token, token, token)
+	args := append([]interface{}{token, token}, visibleToArgs(token)...)
+	photos, err := db.queryPhotos(token, nil, "SELECT "+photoColumns+" FROM photo JOIN user u ON u.token = photo.owner WHERE owner != ? AND owner IN (SELECT followed FROM follow WHERE following=?) AND published = 1 AND "+photoVisibleTo+" ORDER BY created_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	reposts, err := db.getStreamReposts(token)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var photos []Photo
-	for rows.Next() {
-		var photo Photo
-		err := rows.Scan(&photo.Id, &photo.Owner, &photo.OwnerUsername, &photo.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
 
-		// Add additional photo data
-		photo.NumberOfLikes, err = db.GetNumberOfLikes(photo.Id)
-		if err != nil {
-			return nil, err
-		}
-		photo.NumberOfComments, err = db.GetNumberOfComments(photo.Id)
-		if err != nil {
-			return nil, err
-		}
-		photo.IsLiked, err = db.CheckLike(token, photo.Id)
+	// Reposts are placed in the stream by the time they were reposted
+	type streamEntry struct {
+		photo   Photo
+		entered time.Time
+	}
+	entries := make([]streamEntry, 0, len(photos)+len(reposts))
+	for _, photo := range append(photos, reposts...) {
+		entered, err := streamTime(photo)
 		if err != nil {
 			return nil, err
 		}
-
-		photos = append(photos, photo)
+		entries = append(entries, streamEntry{photo, entered})
 	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].entered.After(entries[j].entered)
+	})
 
-	if rows.Err() != nil {
-		return photos, rows.Err()
+	stream := make([]Photo, len(entries))
+	for i, entry := range entries {
+		stream[i] = entry.photo
 	}
+	return stream, nil
+}
 
-	return photos, nil
+// streamTime returns when the photo entered the stream
+func streamTime(photo Photo) (time.Time, error) {
+	if photo.RepostedBy != "" {
+		return time.Parse(time.RFC3339, photo.RepostedAt)
+	}
+	return time.Parse(time.RFC3339, photo.CreatedAt)
 }
 
 // Checking Photo Existence
 func (db *appdbimpl) CheckPhotoExistence(photoId int64) (bool, error) {
-	return db.checkExistence("SELECT count(*) FROM photo WHERE id=?", photoId)
+	return db.checkExistence("SELECT count(*) FROM photo WHERE id=? AND deleted_at IS NULL", photoId)
 }
 
 // Additional Helper Functions for Likes and Comments
 func (db *appdbimpl) GetNumberOfLikes(photoId int64) (int64, error) {
 	var count int64
-	err := db.c.QueryRow("SELECT count(*) FROM like WHERE photo=?", photoId).Scan(&count)
+	err := db.c.QueryRow("SELECT count(*) FROM likes WHERE photo=? AND reaction=?", photoId, ReactionLike).Scan(&count)
 	return count, err
 }
 
 func (db *appdbimpl) GetNumberOfComments(photoId int64) (int64, error) {
 	var count int64
-	err := db.c.QueryRow("SELECT count(*) FROM comment WHERE photo=?", photoId).Scan(&count)
+	err := db.c.QueryRow("SELECT count(*) FROM comment WHERE photo=? AND deleted_at IS NULL AND hidden = 0", photoId).Scan(&count)
 	return count, err
 }
@@ -0,0 +1,29 @@
+package database
+
+import "WasaPhoto/service/globaltime"
+
+// MaxPinnedPhotos is the maximum number of photos a user can pin on their profile
+const MaxPinnedPhotos = 3
+
+// PinPhoto pins the photo at the top of the profile of its owner. It returns false if the owner already pinned
+// MaxPinnedPhotos photos, which is checked by the same statement so that concurrent requests cannot exceed it.
+func (db *appdbimpl) PinPhoto(photoId int64) (bool, error) {
+	res, err := db.c.Exec(`UPDATE photo SET pinned_at=? WHERE id=?
+		AND (SELECT count(*) FROM photo p WHERE p.owner = photo.owner AND p.pinned_at IS NOT NULL) < ?`,
+		formatTime(globaltime.Now()), photoId, MaxPinnedPhotos)
+	if err != nil {
+		return false, err
+	}
+	pinned, err := res.RowsAffected()
+	return pinned == 1, err
+}
+
+// UnpinPhoto removes the photo from the pinned photos of its owner.
+func (db *appdbimpl) UnpinPhoto(photoId int64) error {
+	return db.execQuery("UPDATE photo SET pinned_at=NULL WHERE id=?", photoId)
+}
+
+// CheckPinned checks if the photo is pinned.
+func (db *appdbimpl) CheckPinned(photoId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM photo WHERE id=? AND pinned_at IS NOT NULL", photoId)
+}
@@ -105,6 +105,12 @@ func (db *appdbimpl) CheckToken(token int64) bool {
 	return err == nil && count == 1
 }
 
+// GetUsername returns the current username of a user.
+func (db *appdbimpl) GetUsername(token int64) (string, error) {
+	_, username, err := db.getUserData(token)
+	return username, err
+}
+
 // Get user data (token and username).
 func (db *appdbimpl) getUserData(token int64) (int64, string, error) {
 	var username string
@@ -118,14 +124,14 @@ func (db *appdbimpl) getUserData(token int64) (int64, string, error) {
 // GetNumberOfLikes returns the number of likes for a given photo.
 func (db *appdbimpl) GetNumberOfLikes(photoId int64) (int64, error) {
 	var count int64
-	err := db.c.QueryRow("SELECT count(*) FROM like WHERE photo=?", photoId).Scan(&count)
+	err := db.c.QueryRow("SELECT count(*) FROM likes WHERE photo=? AND reaction=?", photoId, ReactionLike).Scan(&count)
 	return count, err
 }
 
 // GetNumberOfComments returns the number of comments for a given photo.
 func (db *appdbimpl) GetNumberOfComments(photoId int64) (int64, error) {
 	var count int64
-	err := db.c.QueryRow("SELECT count(*) FROM comment WHERE photo=?", photoId).Scan(&count)
+	err := db.c.QueryRow("SELECT count(*) FROM comment WHERE photo=? AND deleted_at IS NULL AND hidden = 0", photoId).Scan(&count)
 	return count, err
 }
 
@@ -143,39 +149,12 @@ func (db *appdbimpl) getNumberFollowing(token int64) (int64, error) {
 	return count, err
 }
 
-// GetListOfPhotos retrieves the list of photos for a user, along with likes, comments, and whether the requesting user liked them.
+// GetListOfPhotos retrieves the list of photos of a user visible to the requesting user, along with likes, comments,
+// and whether the requesting user liked them.
+// The pinned photos come first, the most recently pinned first, followed by the others in insertion order.
 func (db *appdbimpl) getListOfPhotos(userToken int64, requestUser int64) ([]Photo, error) {
-	rows, err := db.c.Query("SELECT id, owner, u.username, created_at FROM photo JOIN user u ON u.token = photo.owner WHERE owner=?", userToken)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var photos []Photo
-	for rows.Next() {
-		var photo Photo
-		err = rows.Scan(&photo.Id, &photo.Owner, &photo.OwnerUsername, &photo.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-
-		// Fetch additional photo details
-		photo.NumberOfLikes, err = db.GetNumberOfLikes(photo.Id)
-		if err != nil {
-			return nil, err
-		}
-		photo.NumberOfComments, err = db.GetNumberOfComments(photo.Id)
-		if err != nil {
-			return nil, err
-		}
-		photo.IsLiked, err = db.CheckLike(requestUser, photo.Id)
-		if err != nil {
-			return nil, err
-		}
-		photos = append(photos, photo)
-	}
-
-	return photos, rows.Err()
+	args := append([]interface{}{userToken}, visibleToArgs(requestUser)...)
+	return db.queryPhotos(requestUser, nil, "SELECT "+photoColumns+" FROM photo JOIN user u ON u.token = photo.owner WHERE owner=? AND published = 1 AND "+photoVisibleTo+" ORDER BY photo.pinned_at IS NULL, photo.pinned_at DESC, photo.id", args...)
 }
 
 // AddUser adds a new user to the database and returns the newly created user's token.
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+	"errors"
+)
+
+// ReactionLike is the reaction recorded by the like endpoints
+const ReactionLike = "❤️"
+
+// DefaultReactions are the reactions available when none are configured
+var DefaultReactions = []string{ReactionLike, "😂", "😮", "😢", "🔥"}
+
+// SetReaction records the reaction of the user to the photo, replacing their previous one.
+func (db *appdbimpl) SetReaction(token int64, photoId int64, reaction string) error {
+	return db.execQuery(`INSERT INTO likes (owner, photo, reaction, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (owner, photo) DO UPDATE SET reaction = excluded.reaction, created_at = excluded.created_at`,
+		token, photoId, reaction, formatTime(globaltime.Now()))
+}
+
+// RemoveReaction removes the reaction of the user to the photo, whatever it is.
+func (db *appdbimpl) RemoveReaction(token int64, photoId int64) error {
+	return db.execQuery("DELETE FROM likes WHERE owner=? AND photo=?", token, photoId)
+}
+
+// GetReaction returns the reaction of the user to the photo, or an empty string if they did not react.
+func (db *appdbimpl) GetReaction(token int64, photoId int64) (string, error) {
+	var reaction string
+	err := db.c.QueryRow("SELECT reaction FROM likes WHERE owner=? AND photo=?", token, photoId).Scan(&reaction)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return reaction, err
+}
+
+// GetReactionCounts returns how many users reacted to the photo with each reaction. The reactions nobody used are
+// left out.
+func (db *appdbimpl) GetReactionCounts(photoId int64) (map[string]int64, error) {
+	rows, err := db.c.Query("SELECT reaction, count(*) FROM likes WHERE photo=? GROUP BY reaction", photoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var reaction string
+		var count int64
+		if err := rows.Scan(&reaction, &count); err != nil {
+			return nil, err
+		}
+		counts[reaction] = count
+	}
+	return counts, rows.Err()
+}
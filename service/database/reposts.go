@@ -0,0 +1,54 @@
+package database
+
+import "WasaPhoto/service/globaltime"
+
+// Repost shares the photo with the followers of the user, and notifies its author.
+func (db *appdbimpl) Repost(token int64, photoId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var owner int64
+	if err := tx.QueryRow("SELECT owner FROM photo WHERE id=?", photoId).Scan(&owner); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO repost (owner, photo, created_at) VALUES (?, ?, ?)", token, photoId, formatTime(globaltime.Now())); err != nil {
+		return err
+	}
+	if err := addNotification(tx, owner, token, NotificationRepost, photoId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// reposterVisible is the condition of the rows of `repost` shown in the stream of a user: the reposter is followed by
+// the user and there is no ban between them. The placeholders must be bound with the user three times.
+const reposterVisible = `owner IN (SELECT followed FROM follow WHERE following = ?)
+	AND owner NOT IN (SELECT banning FROM ban WHERE banned = ?) AND owner NOT IN (SELECT banned FROM ban WHERE banning = ?)`
+
+// getStreamReposts returns the photos reposted by the users followed by the viewer that the viewer can see. Each photo
+// is returned once, with its most recent repost, and the photos of the viewer or of users they follow are left out as
+// they are already in the stream.
+func (db *appdbimpl) getStreamReposts(viewer int64) ([]Photo, error) {
+	repost := func(photo *Photo) []interface{} { return []interface{}{&photo.RepostedBy, &photo.RepostedAt} }
+	args := []interface{}{viewer, viewer, viewer, viewer, viewer, viewer}
+	args = append(args, visibleToArgs(viewer)...)
+	return db.queryPhotos(viewer, repost, "SELECT "+photoColumns+`, ru.username, r.created_at
+		FROM repost r JOIN photo ON photo.id = r.photo JOIN user u ON u.token = photo.owner JOIN user ru ON ru.token = r.owner
+		WHERE r.rowid = (SELECT rowid FROM repost WHERE photo = r.photo AND `+reposterVisible+` ORDER BY created_at DESC, rowid DESC LIMIT 1)
+			AND photo.owner != ? AND photo.owner NOT IN (SELECT followed FROM follow WHERE following = ?)
+			AND photo.owner NOT IN (SELECT banned FROM ban WHERE banning = ?)
+			AND photo.published = 1 AND `+photoVisibleTo, args...)
+}
+
+// RemoveRepost stops sharing the photo with the followers of the user.
+func (db *appdbimpl) RemoveRepost(token int64, photoId int64) error {
+	return db.execQuery("DELETE FROM repost WHERE owner=? AND photo=?", token, photoId)
+}
+
+// CheckRepost checks if the user reposted the photo.
+func (db *appdbimpl) CheckRepost(token int64, photoId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM repost WHERE owner=? AND photo=?", token, photoId)
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+)
+
+// PhotoEdit is a change of the caption and location of a photo made by its owner
+type PhotoEdit struct {
+	// Caption, if not nil, replaces the caption of the photo
+	Caption *string
+
+	// Location, if not nil, replaces the location of the photo
+	Location *PhotoLocation
+
+	// RemoveLocation removes the location of the photo, it is ignored if Location is set
+	RemoveLocation bool
+}
+
+// saveRevision keeps the current version of the photo in its history before it is edited, and marks the photo as
+// edited. The image is only kept when it is about to be replaced, the revisions without an image have the image of the
+// next revision.
+func saveRevision(tx *sql.Tx, photoId int64, keepImage bool) error {
+	_, err := tx.Exec(`INSERT INTO photo_revision (photo, revision, caption, latitude, longitude, place_name, img, created_at)
+		SELECT id, (SELECT count(*) + 1 FROM photo_revision WHERE photo = photo.id), caption, latitude, longitude, place_name,
+			CASE WHEN ? THEN img END, COALESCE(edited_at, created_at)
+		FROM photo WHERE id=?`, keepImage, photoId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE photo SET edited_at=? WHERE id=?", formatTime(globaltime.Now()), photoId)
+	return err
+}
+
+// EditPhoto changes the caption and the location of the photo, keeping the previous version in its history. Likes and
+// comments are not affected.
+func (db *appdbimpl) EditPhoto(photoId int64, edit PhotoEdit) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := saveRevision(tx, photoId, false); err != nil {
+		return err
+	}
+	if edit.Caption != nil {
+		if _, err := tx.Exec("UPDATE photo SET caption=? WHERE id=?", *edit.Caption, photoId); err != nil {
+			return err
+		}
+		if err := setPhotoTags(tx, photoId, *edit.Caption); err != nil {
+			return err
+		}
+		var owner int64
+		if err := tx.QueryRow("SELECT owner FROM photo WHERE id=?", photoId).Scan(&owner); err != nil {
+			return err
+		}
+		if err := setMentions(tx, photoMentions, photoId, owner, *edit.Caption, NotificationMention, photoId); err != nil {
+			return err
+		}
+	}
+	if edit.Location != nil {
+		if err := setLocation(tx, photoId, *edit.Location); err != nil {
+			return err
+		}
+	} else if edit.RemoveLocation {
+		for _, query := range []string{
+			"UPDATE photo SET latitude=NULL, longitude=NULL, place_name=NULL WHERE id=?",
+			"DELETE FROM photo_location WHERE id=?",
+		} {
+			if _, err := tx.Exec(query, photoId); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// ReplaceImage replaces the image of the photo, keeping the previous one in its history. The edits of the previous
+// image are discarded, while likes and comments are not affected.
+func (db *appdbimpl) ReplaceImage(photoId int64, image []byte, animation *PhotoAnimation) error {
+	animated, frameCount, durationMs, poster := false, 1, int64(0), []byte(nil)
+	if animation != nil {
+		animated, frameCount, durationMs, poster = true, animation.FrameCount, animation.Duration.Milliseconds(), animation.Poster
+	}
+
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := saveRevision(tx, photoId, true); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE photo SET img=?, is_animated=?, frame_count=?, duration_ms=?, poster=?, edit_recipe=NULL WHERE id=?",
+		image, animated, frameCount, durationMs, poster, photoId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPhotoHistory returns the previous versions of the photo, the oldest first.
+func (db *appdbimpl) GetPhotoHistory(photoId int64) ([]PhotoRevision, error) {
+	rows, err := db.c.Query(`SELECT revision, caption, latitude, longitude, place_name, img IS NOT NULL, created_at
+		FROM photo_revision WHERE photo=? ORDER BY revision`, photoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []PhotoRevision
+	for rows.Next() {
+		var revision PhotoRevision
+		var latitude, longitude sql.NullFloat64
+		var placeName sql.NullString
+		if err := rows.Scan(&revision.Revision, &revision.Caption, &latitude, &longitude, &placeName, &revision.ImageReplaced, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		if latitude.Valid && longitude.Valid {
+			revision.Location = &Location{Latitude: latitude.Float64, Longitude: longitude.Float64, Name: placeName.String}
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevisionImage returns the image of the photo at the given revision. Images are only stored in the revisions saved
+// before a replacement, so it is the image of the first of them from the given revision on, or the current image if it
+// has not been replaced since. It returns sql.ErrNoRows if the revision does not exist.
+func (db *appdbimpl) GetRevisionImage(photoId int64, revision int64) ([]byte, error) {
+	var image []byte
+	err := db.c.QueryRow(`SELECT COALESCE(
+			(SELECT img FROM photo_revision WHERE photo = photo.id AND revision >= ? AND img IS NOT NULL ORDER BY revision LIMIT 1),
+			img)
+		FROM photo WHERE id=? AND EXISTS (SELECT 1 FROM photo_revision WHERE photo = photo.id AND revision = ?)`,
+		revision, photoId, revision).Scan(&image)
+	return image, err
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"database/sql"
+)
+
+// SavePhoto adds the photo to the saved photos of the user, the default collection.
+func (db *appdbimpl) SavePhoto(token int64, photoId int64) error {
+	return db.execQuery("INSERT OR IGNORE INTO saved (owner, photo, saved_at) VALUES (?, ?, ?)", token, photoId, formatTime(globaltime.Now()))
+}
+
+// UnsavePhoto removes the photo from the saved photos of the user, and from all their collections.
+func (db *appdbimpl) UnsavePhoto(token int64, photoId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := unsave(tx, "saved.owner=? AND saved.photo=?", token, photoId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// unsave removes the rows of `saved` matching the condition, along with the same photos in the collections of their
+// owners. The columns in the condition must be qualified with the table name.
+func unsave(tx *sql.Tx, condition string, args ...interface{}) error {
+	_, err := tx.Exec(`DELETE FROM collection_photo WHERE EXISTS (
+		SELECT 1 FROM saved JOIN collection c ON c.owner = saved.owner
+		WHERE c.id = collection_photo.collection AND saved.photo = collection_photo.photo AND `+condition+`)`, args...)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM saved WHERE "+condition, args...)
+	return err
+}
+
+// CheckSaved checks if the user saved the photo.
+func (db *appdbimpl) CheckSaved(token int64, photoId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM saved WHERE owner=? AND photo=?", token, photoId)
+}
+
+// GetSavedPhotos returns the saved photos of the user that they can still see, the most recently saved first.
+func (db *appdbimpl) GetSavedPhotos(token int64) ([]Photo, error) {
+	args := append([]interface{}{token, token}, visibleToArgs(token)...)
+	return db.queryPhotos(token, nil, "SELECT "+photoColumns+" FROM saved s JOIN photo ON photo.id = s.photo JOIN user u ON u.token = photo.owner WHERE s.owner=? AND photo.published = 1 AND photo.owner NOT IN (SELECT banned FROM ban WHERE banning = ?) AND "+photoVisibleTo+" ORDER BY s.saved_at DESC, photo.id DESC", args...)
+}
+
+// CreateCollection creates an empty named collection and returns its id.
+func (db *appdbimpl) CreateCollection(token int64, name string) (int64, error) {
+	res, err := db.c.Exec("INSERT INTO collection (owner, name, created_at) VALUES (?, ?, ?)", token, name, formatTime(globaltime.Now()))
+	if err != nil {
+		return -1, err
+	}
+	return res.LastInsertId()
+}
+
+// RenameCollection changes the name of the collection.
+func (db *appdbimpl) RenameCollection(collectionId int64, name string) error {
+	return db.execQuery("UPDATE collection SET name=? WHERE id=?", name, collectionId)
+}
+
+// DeleteCollection deletes the collection. Its photos stay in the saved photos.
+func (db *appdbimpl) DeleteCollection(collectionId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM collection_photo WHERE collection=?", collectionId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM collection WHERE id=?", collectionId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckCollectionOwner checks if the collection exists and belongs to the user.
+func (db *appdbimpl) CheckCollectionOwner(token int64, collectionId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM collection WHERE id=? AND owner=?", collectionId, token)
+}
+
+// CheckCollectionName checks if the user already has a collection with the name.
+func (db *appdbimpl) CheckCollectionName(token int64, name string) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM collection WHERE owner=? AND name=?", token, name)
+}
+
+// GetCollections returns the named collections of the user, with the number of photos in each that GetCollectionPhotos
+// returns.
+func (db *appdbimpl) GetCollections(token int64) ([]Collection, error) {
+	args := append(listedToArgs(token), token)
+	rows, err := db.c.Query(`SELECT c.id, c.name, c.created_at,
+		(SELECT count(*) FROM collection_photo cp JOIN photo ON photo.id = cp.photo WHERE cp.collection = c.id AND `+photoListedTo+`)
+		FROM collection c WHERE c.owner=? ORDER BY c.created_at, c.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		var collection Collection
+		if err := rows.Scan(&collection.Id, &collection.Name, &collection.CreatedAt, &collection.NumberOfPhotos); err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollectionPhotos returns the photos of the collection that its owner can still see, the most recently added
+// first.
+func (db *appdbimpl) GetCollectionPhotos(token int64, collectionId int64) ([]Photo, error) {
+	args := append([]interface{}{collectionId}, listedToArgs(token)...)
+	return db.queryPhotos(token, nil, "SELECT "+photoColumns+" FROM collection_photo cp JOIN photo ON photo.id = cp.photo JOIN user u ON u.token = photo.owner WHERE cp.collection=? AND "+photoListedTo+" ORDER BY cp.added_at DESC, photo.id DESC", args...)
+}
+
+// AddCollectionPhoto adds the photo to the collection of the user, saving it if it was not saved yet.
+func (db *appdbimpl) AddCollectionPhoto(token int64, collectionId int64, photoId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := formatTime(globaltime.Now())
+	if _, err := tx.Exec("INSERT OR IGNORE INTO saved (owner, photo, saved_at) VALUES (?, ?, ?)", token, photoId, now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT OR IGNORE INTO collection_photo (collection, photo, added_at) VALUES (?, ?, ?)", collectionId, photoId, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveCollectionPhoto removes the photo from the collection. The photo stays in the saved photos.
+func (db *appdbimpl) RemoveCollectionPhoto(collectionId int64, photoId int64) error {
+	return db.execQuery("DELETE FROM collection_photo WHERE collection=? AND photo=?", collectionId, photoId)
+}
+
+// CheckCollectionPhoto checks if the photo is in the collection.
+func (db *appdbimpl) CheckCollectionPhoto(collectionId int64, photoId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM collection_photo WHERE collection=? AND photo=?", collectionId, photoId)
+}
@@ -10,9 +10,20 @@ func (db *appdbimpl) AddFollow(following int64, followUsername string) error {
 		return err
 	}
 
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
 	// Insert the follow relationship into the database
-	_, err = db.c.Exec("INSERT INTO follow (following, followed) VALUES (?, ?)", following, followed)
-	return err
+	if _, err := tx.Exec("INSERT INTO follow (following, followed) VALUES (?, ?)", following, followed); err != nil {
+		return err
+	}
+	if err := recordFollowEvent(tx, following, followed, followEventFollow); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // RemoveFollow removes a follow relationship between the following user and the user being unfollowed.
@@ -25,9 +36,24 @@ func (db *appdbimpl) RemoveFollow(following int64, unfollowUsername string) erro
 		return err
 	}
 
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
 	// Remove the follow relationship from the database
-	_, err = db.c.Exec("DELETE FROM follow WHERE following=? AND followed=?", following, followed)
-	return err
+	res, err := tx.Exec("DELETE FROM follow WHERE following=? AND followed=?", following, followed)
+	if err != nil {
+		return err
+	}
+	if removed, err := res.RowsAffected(); err != nil || removed == 0 {
+		return err
+	}
+	if err := recordFollowEvent(tx, following, followed, followEventUnfollow); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // AddBan adds a ban relationship between the banning user and the user being banned.
@@ -40,9 +66,45 @@ func (db *appdbimpl) AddBan(banning int64, banUsername string) error {
 		return err
 	}
 
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
 	// Insert the ban relationship into the database
-	_, err = db.c.Exec("INSERT INTO ban (banning, banned) VALUES (?, ?)", banning, banned)
-	return err
+	if _, err := tx.Exec("INSERT INTO ban (banning, banned) VALUES (?, ?)", banning, banned); err != nil {
+		return err
+	}
+
+	// Remove the tags between the two users, in the photos of either of them
+	_, err = tx.Exec(`DELETE FROM person_tag WHERE
+		(user=? AND photo IN (SELECT id FROM photo WHERE owner=?)) OR
+		(user=? AND photo IN (SELECT id FROM photo WHERE owner=?))`, banned, banning, banning, banned)
+	if err != nil {
+		return err
+	}
+
+	// The banned user loses the photos of the banning user they saved
+	if err := unsave(tx, "saved.owner=? AND saved.photo IN (SELECT id FROM photo WHERE owner=?)", banned, banning); err != nil {
+		return err
+	}
+
+	// Remove the reposts of the photos of each user by the other one
+	_, err = tx.Exec(`DELETE FROM repost WHERE
+		(owner=? AND photo IN (SELECT id FROM photo WHERE owner=?)) OR
+		(owner=? AND photo IN (SELECT id FROM photo WHERE owner=?))`, banned, banning, banning, banned)
+	if err != nil {
+		return err
+	}
+
+	// Remove each user from the albums of the other one
+	err = removeAlbumMembers(tx, `(user=? AND album IN (SELECT id FROM album WHERE owner=?)) OR
+		(user=? AND album IN (SELECT id FROM album WHERE owner=?))`, banned, banning, banning, banned)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // RemoveBan removes a ban relationship between the banning user and the banned user.
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"WasaPhoto/service/globaltime"
+	"time"
+)
+
+// StoryLifetime is how long a story stays visible after being posted
+const StoryLifetime = 24 * time.Hour
+
+// storyColumns are the columns of `story` read by queryStories, with the owner joined as `u`
+const storyColumns = "story.id, story.owner, u.username, story.created_at, story.expires_at"
+
+// storyViewColumns are the columns added to storyColumns for queryStories, to be used with the viewer
+const storyViewColumns = `, EXISTS (SELECT 1 FROM story_view v WHERE v.story = story.id AND v.viewer = ?),
+	(SELECT count(*) FROM story_view v WHERE v.story = story.id)`
+
+// storyActive is the condition of the stories that have not expired yet, to be used with formatTime(globaltime.Now())
+const storyActive = "story.archived = 0 AND story.expires_at > ?"
+
+// storyNotBanned is the condition of the stories whose owner did not ban the viewer and was not banned by them, to be
+// used with the viewer twice
+const storyNotBanned = "story.owner NOT IN (SELECT banning FROM ban WHERE banned = ?) AND story.owner NOT IN (SELECT banned FROM ban WHERE banning = ?)"
+
+// queryStories runs a query selecting storyColumns and storyViewColumns.
+func (db *appdbimpl) queryStories(query string, args ...interface{}) ([]Story, error) {
+	rows, err := db.c.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stories []Story
+	for rows.Next() {
+		var story Story
+		if err := rows.Scan(&story.Id, &story.Owner, &story.OwnerUsername, &story.CreatedAt, &story.ExpiresAt, &story.Seen, &story.NumberOfViews); err != nil {
+			return nil, err
+		}
+		stories = append(stories, story)
+	}
+	return stories, rows.Err()
+}
+
+// PostStory adds a story that expires after StoryLifetime, and returns its id.
+func (db *appdbimpl) PostStory(token int64, image []byte) (int64, error) {
+	now := globaltime.Now()
+	res, err := db.c.Exec("INSERT INTO story (owner, img, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		token, image, formatTime(now), formatTime(now.Add(StoryLifetime)))
+	if err != nil {
+		return -1, err
+	}
+	return res.LastInsertId()
+}
+
+// DeleteStory deletes the story, active or archived, with its viewers.
+func (db *appdbimpl) DeleteStory(storyId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM story_view WHERE story=?", storyId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM story WHERE id=?", storyId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckStoryOwner checks if the story, active or archived, belongs to the user.
+func (db *appdbimpl) CheckStoryOwner(token int64, storyId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM story WHERE id=? AND owner=?", storyId, token)
+}
+
+// CanViewStory checks if the story can be seen by the viewer: the owner always can, the other users only while the
+// story is active and there is no ban between them.
+func (db *appdbimpl) CanViewStory(viewer int64, storyId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM story WHERE id=? AND (owner=? OR ("+storyActive+" AND "+storyNotBanned+"))",
+		storyId, viewer, formatTime(globaltime.Now()), viewer, viewer)
+}
+
+// GetStoryImage returns the image of the story.
+func (db *appdbimpl) GetStoryImage(storyId int64) ([]byte, error) {
+	var image []byte
+	err := db.c.QueryRow("SELECT img FROM story WHERE id=?", storyId).Scan(&image)
+	return image, err
+}
+
+// RecordStoryView records that the viewer has seen the story. The views of the owner are not recorded.
+func (db *appdbimpl) RecordStoryView(storyId int64, viewer int64) error {
+	return db.execQuery("INSERT OR IGNORE INTO story_view (story, viewer, viewed_at) SELECT id, ?, ? FROM story WHERE id=? AND owner != ?",
+		viewer, formatTime(globaltime.Now()), storyId, viewer)
+}
+
+// GetStoryViewers returns the users who have seen the story, the most recent first.
+func (db *appdbimpl) GetStoryViewers(storyId int64) ([]StoryViewer, error) {
+	rows, err := db.c.Query("SELECT u.username, v.viewed_at FROM story_view v JOIN user u ON u.token = v.viewer WHERE v.story=? ORDER BY v.viewed_at DESC, u.username", storyId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var viewers []StoryViewer
+	for rows.Next() {
+		var viewer StoryViewer
+		if err := rows.Scan(&viewer.Username, &viewer.ViewedAt); err != nil {
+			return nil, err
+		}
+		viewers = append(viewers, viewer)
+	}
+	return viewers, rows.Err()
+}
+
+// GetMyStories returns the active stories of the user, the oldest first.
+func (db *appdbimpl) GetMyStories(token int64) ([]Story, error) {
+	return db.queryStories("SELECT "+storyColumns+storyViewColumns+" FROM story JOIN user u ON u.token = story.owner WHERE story.owner=? AND "+storyActive+" ORDER BY story.created_at, story.id",
+		token, token, formatTime(globaltime.Now()))
+}
+
+// GetStoriesOf returns the active stories of the owner as seen by the viewer, the oldest first. The number of viewers
+// is only returned to the owner.
+func (db *appdbimpl) GetStoriesOf(viewer int64, owner int64) ([]Story, error) {
+	stories, err := db.queryStories("SELECT "+storyColumns+storyViewColumns+" FROM story JOIN user u ON u.token = story.owner WHERE story.owner=? AND "+storyActive+" AND "+storyNotBanned+" ORDER BY story.created_at, story.id",
+		viewer, owner, formatTime(globaltime.Now()), viewer, viewer)
+	if viewer != owner {
+		for i := range stories {
+			stories[i].NumberOfViews = 0
+		}
+	}
+	return stories, err
+}
+
+// GetStoryTray returns the followed users who have active stories, those with stories not seen by the viewer first,
+// then the most recently updated.
+func (db *appdbimpl) GetStoryTray(viewer int64) ([]StoryTrayEntry, error) {
+	rows, err := db.c.Query(`SELECT story.owner, u.username, max(story.created_at),
+			sum(NOT EXISTS (SELECT 1 FROM story_view v WHERE v.story = story.id AND v.viewer = ?)) > 0 AS unseen, count(*)
+		FROM story JOIN user u ON u.token = story.owner
+		WHERE story.owner IN (SELECT followed FROM follow WHERE following = ?) AND `+storyActive+` AND `+storyNotBanned+`
+		GROUP BY story.owner, u.username
+		ORDER BY unseen DESC, max(story.created_at) DESC, u.username`,
+		viewer, viewer, formatTime(globaltime.Now()), viewer, viewer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tray []StoryTrayEntry
+	for rows.Next() {
+		var entry StoryTrayEntry
+		var updatedAt string
+		if err := rows.Scan(&entry.Owner, &entry.Username, &updatedAt, &entry.HasUnseen, &entry.NumberOfStories); err != nil {
+			return nil, err
+		}
+
+		// The aggregate loses the column type, so the time is formatted like the ones read from DATETIME columns
+		updated, err := time.Parse(timeFormat, updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.UpdatedAt = updated.Format(time.RFC3339)
+		tray = append(tray, entry)
+	}
+	return tray, rows.Err()
+}
+
+// GetStoryArchive returns the expired stories kept for the user, the most recent first.
+func (db *appdbimpl) GetStoryArchive(token int64) ([]Story, error) {
+	return db.queryStories("SELECT "+storyColumns+storyViewColumns+" FROM story JOIN user u ON u.token = story.owner WHERE story.owner=? AND story.archived = 1 ORDER BY story.created_at DESC, story.id DESC",
+		token, token)
+}
+
+// GetArchiveStories returns whether the stories of the user are kept in their archive when they expire.
+func (db *appdbimpl) GetArchiveStories(token int64) (bool, error) {
+	var archive bool
+	err := db.c.QueryRow("SELECT archive_stories FROM user WHERE token=?", token).Scan(&archive)
+	return archive, err
+}
+
+// SetArchiveStories sets whether the stories of the user are kept in their archive when they expire.
+func (db *appdbimpl) SetArchiveStories(token int64, archive bool) error {
+	return db.execQuery("UPDATE user SET archive_stories=? WHERE token=?", archive, token)
+}
+
+// ExpireStories moves the stories expired at the given time to the archive of their owners, if they keep one, and
+// deletes the others with their viewers. It returns how many stories have expired.
+func (db *appdbimpl) ExpireStories(now time.Time) (int64, error) {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec("UPDATE story SET archived = 1 WHERE archived = 0 AND expires_at <= ? AND owner IN (SELECT token FROM user WHERE archive_stories = 1)", formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	archived, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	expired := "SELECT id FROM story WHERE archived = 0 AND expires_at <= ?"
+	if _, err := tx.Exec("DELETE FROM story_view WHERE story IN ("+expired+")", formatTime(now)); err != nil {
+		return 0, err
+	}
+	res, err = tx.Exec("DELETE FROM story WHERE id IN ("+expired+")", formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return archived + deleted, tx.Commit()
+}
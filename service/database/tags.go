@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxHashtagLength is the maximum number of characters of a hashtag, longer ones are ignored
+const maxHashtagLength = 50
+
+// hashtagPattern matches a `#` at the start of the text or after a character that cannot be part of a word, followed
+// by the letters, digits and underscores of the tag
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+
+// Hashtags returns the normalized hashtags found in a caption, without duplicates and in order of appearance. Tags
+// made only of digits or longer than maxHashtagLength are ignored.
+func Hashtags(caption string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(caption, -1) {
+		tag := NormalizeHashtag(match[1])
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// NormalizeHashtag returns the canonical form of a tag, without the leading `#`, or an empty string if it is not a
+// valid tag.
+func NormalizeHashtag(tag string) string {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || len([]rune(tag)) > maxHashtagLength {
+		return ""
+	}
+	letters := false
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return ""
+		}
+		letters = letters || !unicode.IsDigit(r)
+	}
+	if !letters {
+		return ""
+	}
+	return tag
+}
+
+// setPhotoTags replaces the tags of the photo with the hashtags of the caption, and updates the usage counts of the
+// tags involved. Tags not used anymore are deleted.
+func setPhotoTags(tx *sql.Tx, photoId int64, caption string) error {
+	previous, err := photoTagIds(tx, photoId)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM photo_tag WHERE photo=?", photoId); err != nil {
+		return err
+	}
+
+	affected := previous
+	for _, name := range Hashtags(caption) {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tag (name) VALUES (?)", name); err != nil {
+			return err
+		}
+		var tagId int64
+		if err := tx.QueryRow("SELECT id FROM tag WHERE name=?", name).Scan(&tagId); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO photo_tag (photo, tag) VALUES (?, ?)", photoId, tagId); err != nil {
+			return err
+		}
+		affected = append(affected, tagId)
+	}
+
+	for _, tagId := range affected {
+		if _, err := tx.Exec("UPDATE tag SET usage_count = (SELECT count(*) FROM photo_tag WHERE tag = tag.id) WHERE id=?", tagId); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("DELETE FROM tag WHERE usage_count = 0")
+	return err
+}
+
+// photoTagIds returns the ids of the tags of the photo.
+func photoTagIds(tx *sql.Tx, photoId int64) ([]int64, error) {
+	rows, err := tx.Query("SELECT tag FROM photo_tag WHERE photo=?", photoId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetCaption changes the caption of the photo along with its hashtags, keeping the previous one in its history.
+func (db *appdbimpl) SetCaption(photoId int64, caption string) error {
+	return db.EditPhoto(photoId, PhotoEdit{Caption: &caption})
+}
+
+// GetTag returns the tag with the number of its photos visible to the viewer, the same listed by GetTagPhotos. It
+// returns sql.ErrNoRows if the viewer cannot see any photo with the tag, so that the tags of hidden photos are not
+// disclosed.
+func (db *appdbimpl) GetTag(viewer int64, name string) (Tag, error) {
+	var tag Tag
+	args := append([]interface{}{name}, listedToArgs(viewer)...)
+	err := db.c.QueryRow(`SELECT t.name, count(*) FROM tag t JOIN photo_tag pt ON pt.tag = t.id JOIN photo ON photo.id = pt.photo
+		WHERE t.name=? AND `+photoListedTo+" GROUP BY t.id", args...).Scan(&tag.Name, &tag.NumberOfPhotos)
+	return tag, err
+}
+
+// GetTagPhotos returns the photos with the tag visible to the viewer, most recent first, skipping the photos of the
+// users banned by the viewer. Only photos older than the photo with id before are returned, if before is positive.
+func (db *appdbimpl) GetTagPhotos(viewer int64, name string, before int64, limit int) ([]Photo, error) {
+	query := "SELECT " + photoColumns + " FROM tag t JOIN photo_tag pt ON pt.tag = t.id JOIN photo ON photo.id = pt.photo JOIN user u ON u.token = photo.owner WHERE t.name=?"
+	args := []interface{}{name}
+	if before > 0 {
+		query += " AND photo.id < ?"
+		args = append(args, before)
+	}
+	query += " AND " + photoListedTo + " ORDER BY photo.id DESC LIMIT ?"
+	args = append(args, listedToArgs(viewer)...)
+	args = append(args, limit)
+
+	return db.queryPhotos(viewer, nil, query, args...)
+}
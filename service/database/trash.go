@@ -0,0 +1,92 @@
+package database
+
+import "time"
+
+// GetTrash returns the photos in the trash of the user, most recently deleted first.
+func (db *appdbimpl) GetTrash(token int64) ([]Photo, error) {
+	deletedAt := func(photo *Photo) []interface{} { return []interface{}{&photo.DeletedAt} }
+	return db.queryPhotos(token, deletedAt, "SELECT "+photoColumns+", deleted_at FROM photo JOIN user u ON u.token = photo.owner WHERE owner=? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC", token)
+}
+
+// CheckTrashedPhoto checks if the photo is in the trash of the user.
+func (db *appdbimpl) CheckTrashedPhoto(token int64, photoId int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM photo WHERE id=? AND owner=? AND deleted_at IS NOT NULL", photoId, token)
+}
+
+// RestorePhoto brings a photo back from the trash, along with its likes, comments, and hashtags.
+func (db *appdbimpl) RestorePhoto(token int64, photoId int64) error {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var caption string
+	if err := tx.QueryRow("SELECT caption FROM photo WHERE id=? AND owner=?", photoId, token).Scan(&caption); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE photo SET deleted_at=NULL WHERE id=? AND owner=?", photoId, token); err != nil {
+		return err
+	}
+	if err := setPhotoTags(tx, photoId, caption); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PurgePhoto permanently deletes a photo with its likes and comments.
+func (db *appdbimpl) PurgePhoto(photoId int64) error {
+	_, err := db.purge("id=?", photoId)
+	return err
+}
+
+// PurgeTrash permanently deletes the photos moved to the trash before the given time, and returns how many photos
+// have been deleted.
+func (db *appdbimpl) PurgeTrash(before time.Time) (int64, error) {
+	return db.purge("deleted_at <= ?", formatTime(before))
+}
+
+// purge deletes the photos matching the condition, and everything referencing them, in a single transaction. It
+// returns how many photos have been deleted. The first statement already writes, so no photo can be restored between
+// the deletions.
+func (db *appdbimpl) purge(condition string, args ...interface{}) (int64, error) {
+	tx, err := db.c.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, query := range []string{
+		"DELETE FROM comment_revision WHERE comment IN (SELECT id FROM comment WHERE photo IN (SELECT id FROM photo WHERE " + condition + "))",
+		"DELETE FROM comment_like WHERE comment IN (SELECT id FROM comment WHERE photo IN (SELECT id FROM photo WHERE " + condition + "))",
+		"DELETE FROM comment_mention WHERE id IN (SELECT id FROM comment WHERE photo IN (SELECT id FROM photo WHERE " + condition + "))",
+		"DELETE FROM comment WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM likes WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM collection_photo WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM repost WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM saved WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"UPDATE album SET cover=NULL WHERE cover IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM album_photo WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM photo_view WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM person_tag WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM notification WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM photo_revision WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM photo_tag WHERE photo IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM photo_mention WHERE id IN (SELECT id FROM photo WHERE " + condition + ")",
+		"DELETE FROM photo_location WHERE id IN (SELECT id FROM photo WHERE " + condition + ")",
+	} {
+		if _, err := tx.Exec(query, args...); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM photo WHERE "+condition, args...)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
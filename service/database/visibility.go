@@ -0,0 +1,75 @@
+package database
+
+// Photo visibility levels
+const (
+	VisibilityPublic       = "public"
+	VisibilityFollowers    = "followers"
+	VisibilityCloseFriends = "close_friends"
+	VisibilityOnlyMe       = "only_me"
+)
+
+// photoVisibleTo is the SQL condition selecting the rows of `photo` that a user can see: photos in the trash are
+// hidden, then the owner sees every photo and anybody else only the published photos whose audience includes them,
+// provided they are not banned by the owner. The placeholders must be bound with visibleToArgs.
+const photoVisibleTo = `photo.deleted_at IS NULL AND (photo.owner = ? OR (
+	photo.published = 1 AND photo.owner NOT IN (SELECT banning FROM ban WHERE banned = ?) AND (
+		photo.visibility = 'public'
+		OR (photo.visibility = 'followers' AND photo.owner IN (SELECT followed FROM follow WHERE following = ?))
+		OR (photo.visibility = 'close_friends' AND photo.owner IN (SELECT owner FROM close_friend WHERE friend = ?)))))`
+
+// visibleToArgs returns the arguments for the placeholders of photoVisibleTo.
+func visibleToArgs(viewer int64) []interface{} {
+	return []interface{}{viewer, viewer, viewer, viewer}
+}
+
+// photoListedTo is the SQL condition selecting the rows of `photo` listed to a user in the pages collecting photos of
+// several owners, like hashtags and albums: the published photos visible to the user, skipping the photos of the users
+// banned by the user. The placeholders must be bound with listedToArgs.
+const photoListedTo = "photo.published = 1 AND photo.owner NOT IN (SELECT banned FROM ban WHERE banning = ?) AND " + photoVisibleTo
+
+// listedToArgs returns the arguments for the placeholders of photoListedTo.
+func listedToArgs(viewer int64) []interface{} {
+	return append([]interface{}{viewer}, visibleToArgs(viewer)...)
+}
+
+// CanViewPhoto checks if the photo exists and the viewer is in its audience.
+func (db *appdbimpl) CanViewPhoto(viewer int64, photoId int64) (bool, error) {
+	args := append([]interface{}{photoId}, visibleToArgs(viewer)...)
+	return db.checkExistence("SELECT count(*) FROM photo WHERE photo.id=? AND "+photoVisibleTo, args...)
+}
+
+// SetPhotoVisibility changes the audience of a photo.
+func (db *appdbimpl) SetPhotoVisibility(photoId int64, visibility string) error {
+	return db.execQuery("UPDATE photo SET visibility=? WHERE id=?", visibility, photoId)
+}
+
+// AddCloseFriend adds a user to the close friends of the owner.
+func (db *appdbimpl) AddCloseFriend(owner int64, friendUsername string) error {
+	var friend int64
+
+	// Get the token of the user to add
+	err := db.c.QueryRow("SELECT token FROM user WHERE username=?", friendUsername).Scan(&friend)
+	if err != nil {
+		return err
+	}
+
+	return db.execQuery("INSERT INTO close_friend (owner, friend) VALUES (?, ?)", owner, friend)
+}
+
+// RemoveCloseFriend removes a user from the close friends of the owner.
+func (db *appdbimpl) RemoveCloseFriend(owner int64, friendUsername string) error {
+	var friend int64
+
+	// Get the token of the user to remove
+	err := db.c.QueryRow("SELECT token FROM user WHERE username=?", friendUsername).Scan(&friend)
+	if err != nil {
+		return err
+	}
+
+	return db.execQuery("DELETE FROM close_friend WHERE owner=? AND friend=?", owner, friend)
+}
+
+// CheckCloseFriend checks if friend is one of the close friends of owner.
+func (db *appdbimpl) CheckCloseFriend(owner int64, friend int64) (bool, error) {
+	return db.checkExistence("SELECT count(*) FROM close_friend WHERE owner=? AND friend=?", owner, friend)
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPhotoAudience(t *testing.T) {
+	db := newTestDatabase(t)
+	users := map[string]int64{}
+	for _, username := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+		token, err := db.GetUserToken(username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users[username] = token
+	}
+	owner := users["alice"]
+
+	// bob follows alice and carol is one of her close friends. erin follows alice, who banned her, and frank follows
+	// alice but banned her.
+	for _, follower := range []string{"bob", "erin", "frank"} {
+		if err := db.AddFollow(users[follower], "alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.AddCloseFriend(owner, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddBan(owner, "erin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddBan(users["frank"], "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	photos := []struct {
+		name    string
+		options PhotoOptions
+	}{
+		{"public", PhotoOptions{Visibility: VisibilityPublic}},
+		{"followers", PhotoOptions{Visibility: VisibilityFollowers}},
+		{"close friends", PhotoOptions{Visibility: VisibilityCloseFriends}},
+		{"only me", PhotoOptions{Visibility: VisibilityOnlyMe}},
+		{"draft", PhotoOptions{Visibility: VisibilityPublic, Draft: true}},
+		{"trash", PhotoOptions{Visibility: VisibilityPublic}},
+	}
+	for _, photo := range photos {
+		if err := db.PostPhoto([]byte(photo.name), owner, photo.options); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const trashed = 6
+	if err := db.DeletePhoto(owner, trashed); err != nil {
+		t.Fatal(err)
+	}
+
+	// audience returns the names of the photos selected by the condition for the viewer
+	audience := func(condition string, args []interface{}) []string {
+		t.Helper()
+		rows, err := db.c.Query("SELECT id FROM photo WHERE "+condition+" ORDER BY id", args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		names := []string{}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, photos[id-1].name)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+
+	tests := []struct {
+		viewer  string
+		visible []string
+		listed  []string
+	}{
+		{"alice", []string{"public", "followers", "close friends", "only me", "draft"}, []string{"public", "followers", "close friends", "only me"}},
+		{"bob", []string{"public", "followers"}, []string{"public", "followers"}},
+		{"carol", []string{"public", "close friends"}, []string{"public", "close friends"}},
+		{"dave", []string{"public"}, []string{"public"}},
+		{"erin", []string{}, []string{}},
+		{"frank", []string{"public", "followers"}, []string{}},
+	}
+	for _, tt := range tests {
+		viewer := users[tt.viewer]
+		if got := audience(photoVisibleTo, visibleToArgs(viewer)); !reflect.DeepEqual(got, tt.visible) {
+			t.Errorf("%s can see %v, want %v", tt.viewer, got, tt.visible)
+		}
+		if got := audience(photoListedTo, listedToArgs(viewer)); !reflect.DeepEqual(got, tt.listed) {
+			t.Errorf("%s is listed %v, want %v", tt.viewer, got, tt.listed)
+		}
+
+		for id, photo := range photos {
+			want := false
+			for _, name := range tt.visible {
+				want = want || name == photo.name
+			}
+			canView, err := db.CanViewPhoto(viewer, int64(id+1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if canView != want {
+				t.Errorf("CanViewPhoto(%s, %s) = %v, want %v", tt.viewer, photo.name, canView, want)
+			}
+		}
+	}
+}
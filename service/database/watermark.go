@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Watermark kinds
+const (
+	WatermarkUsername = "username"
+	WatermarkImage    = "image"
+)
+
+// GetWatermark returns the watermark settings of a user. The Kind is empty if the user has no watermark.
+func (db *appdbimpl) GetWatermark(owner int64) (Watermark, error) {
+	var watermark Watermark
+	err := db.c.QueryRow("SELECT kind, position, opacity FROM watermark WHERE owner=?", owner).Scan(&watermark.Kind, &watermark.Position, &watermark.Opacity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Watermark{}, nil
+	}
+	return watermark, err
+}
+
+// SetWatermark creates or replaces the watermark settings of a user.
+func (db *appdbimpl) SetWatermark(owner int64, kind string, position string, opacity float64) error {
+	return db.execQuery(`INSERT INTO watermark (owner, kind, position, opacity) VALUES (?, ?, ?, ?)
+		ON CONFLICT (owner) DO UPDATE SET kind=excluded.kind, position=excluded.position, opacity=excluded.opacity`,
+		owner, kind, position, opacity)
+}
+
+// DeleteWatermark turns off the watermark of a user. The uploaded watermark image is kept.
+func (db *appdbimpl) DeleteWatermark(owner int64) error {
+	return db.execQuery("DELETE FROM watermark WHERE owner=?", owner)
+}
+
+// GetWatermarkImage returns the PNG watermark uploaded by a user, or nil if there is none.
+func (db *appdbimpl) GetWatermarkImage(owner int64) ([]byte, error) {
+	var image []byte
+	err := db.c.QueryRow("SELECT img FROM watermark_image WHERE owner=?", owner).Scan(&image)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return image, err
+}
+
+// SetWatermarkImage creates or replaces the PNG watermark of a user.
+func (db *appdbimpl) SetWatermarkImage(owner int64, image []byte) error {
+	return db.execQuery("INSERT INTO watermark_image (owner, img) VALUES (?, ?) ON CONFLICT (owner) DO UPDATE SET img=excluded.img", owner, image)
+}
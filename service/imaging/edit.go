@@ -0,0 +1,144 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// Named filters accepted in Recipe.Filter
+const (
+	FilterGrayscale = "grayscale"
+	FilterSepia     = "sepia"
+	FilterInvert    = "invert"
+)
+
+// Rect is a rectangle in the pixel coordinates of the original image.
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Recipe describes the edits applied to an original image. The edits are applied in the order of the fields: first
+// the crop, then the rotation, the brightness and contrast adjustments, and finally the filter.
+type Recipe struct {
+	// Crop is the part of the original image to keep, nil to keep the whole image
+	Crop *Rect `json:"crop,omitempty"`
+
+	// Rotate is the number of 90° clockwise rotations, between 0 and 3
+	Rotate int `json:"rotate,omitempty"`
+
+	// Brightness is added to every channel, between -1 (black) and 1 (white)
+	Brightness float64 `json:"brightness,omitempty"`
+
+	// Contrast scales the distance of every channel from the middle gray, between -1 (flat gray) and 1
+	Contrast float64 `json:"contrast,omitempty"`
+
+	// Filter is one of the named filters, empty for none
+	Filter string `json:"filter,omitempty"`
+}
+
+// Validate checks that the recipe can be applied to an image with the given bounds.
+func (rc Recipe) Validate(bounds image.Rectangle) error {
+	if rc.Crop != nil {
+		crop := image.Rect(rc.Crop.X, rc.Crop.Y, rc.Crop.X+rc.Crop.Width, rc.Crop.Y+rc.Crop.Height)
+		if rc.Crop.Width <= 0 || rc.Crop.Height <= 0 || !crop.In(bounds.Sub(bounds.Min)) {
+			return errors.New("the crop rectangle must be inside the image")
+		}
+	}
+	if rc.Rotate < 0 || rc.Rotate > 3 {
+		return errors.New("the rotation must be between 0 and 3 quarter turns")
+	}
+	if math.Abs(rc.Brightness) > 1 || math.Abs(rc.Contrast) > 1 {
+		return errors.New("brightness and contrast must be between -1 and 1")
+	}
+	switch rc.Filter {
+	case "", FilterGrayscale, FilterSepia, FilterInvert:
+	default:
+		return errors.New("unknown filter")
+	}
+	return nil
+}
+
+// Apply returns a new image with the recipe applied to img. The recipe must be valid for the bounds of img.
+func (rc Recipe) Apply(img image.Image) *image.NRGBA {
+	src := img.Bounds()
+	if rc.Crop != nil {
+		src = image.Rect(rc.Crop.X, rc.Crop.Y, rc.Crop.X+rc.Crop.Width, rc.Crop.Y+rc.Crop.Height).Add(src.Min)
+	}
+
+	width, height := src.Dx(), src.Dy()
+	if rc.Rotate%2 == 1 {
+		width, height = height, width
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < src.Dy(); y++ {
+		for x := 0; x < src.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(src.Min.X+x, src.Min.Y+y)).(color.NRGBA)
+
+			// Destination of the pixel after rotating clockwise
+			dx, dy := x, y
+			switch rc.Rotate {
+			case 1:
+				dx, dy = src.Dy()-1-y, x
+			case 2:
+				dx, dy = src.Dx()-1-x, src.Dy()-1-y
+			case 3:
+				dx, dy = y, src.Dx()-1-x
+			}
+			out.SetNRGBA(dx, dy, rc.adjust(c))
+		}
+	}
+	return out
+}
+
+// adjust applies brightness, contrast and filter to a single pixel.
+func (rc Recipe) adjust(c color.NRGBA) color.NRGBA {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+
+	tone := func(v float64) float64 {
+		v = (v-0.5)*(1+rc.Contrast) + 0.5 + rc.Brightness
+		return math.Max(0, math.Min(1, v))
+	}
+	r, g, b = tone(r), tone(g), tone(b)
+
+	switch rc.Filter {
+	case FilterGrayscale:
+		l := 0.299*r + 0.587*g + 0.114*b
+		r, g, b = l, l, l
+	case FilterSepia:
+		r, g, b = math.Min(1, 0.393*r+0.769*g+0.189*b), math.Min(1, 0.349*r+0.686*g+0.168*b), math.Min(1, 0.272*r+0.534*g+0.131*b)
+	case FilterInvert:
+		r, g, b = 1-r, 1-g, 1-b
+	}
+
+	return color.NRGBA{R: uint8(math.Round(r * 255)), G: uint8(math.Round(g * 255)), B: uint8(math.Round(b * 255)), A: c.A}
+}
+
+// Decode decodes an encoded image, returning the image and the name of its format.
+func Decode(data []byte) (image.Image, string, error) {
+	return image.Decode(bytes.NewReader(data))
+}
+
+// Encode encodes the image in the given format: JPEG images stay JPEG, anything else is encoded as PNG. It returns the
+// encoded bytes and their content type.
+func Encode(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
@@ -0,0 +1,127 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// Animation describes an animated GIF.
+type Animation struct {
+	// Frames is the number of frames
+	Frames int
+
+	// Duration is the time needed to play all the frames once
+	Duration time.Duration
+
+	// Poster is the first frame as a PNG image
+	Poster []byte
+}
+
+// InspectAnimation returns the animation data of an encoded image, and false if the image is not an animated GIF. The
+// image is decoded once for both the data and the poster.
+func InspectAnimation(data []byte) (Animation, bool, error) {
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(anim.Image) < 2 {
+		return Animation{}, false, nil
+	}
+
+	info := Animation{Frames: len(anim.Image)}
+	for _, delay := range anim.Delay {
+		// GIF delays are in hundredths of second
+		info.Duration += time.Duration(delay) * 10 * time.Millisecond
+	}
+
+	// Only the first frame is composed, the others do not matter for the poster
+	composeFrames(anim, func(frame *image.NRGBA) bool {
+		info.Poster, _, err = Encode(frame, "png")
+		return false
+	})
+	if err != nil {
+		return Animation{}, false, err
+	}
+	return info, true, nil
+}
+
+// composeFrames calls visit with the frames of the animation as they are displayed, each one drawn over the previous
+// ones according to the disposal methods, until visit returns false. The frame is reused for the next one, so visit
+// must not keep it.
+func composeFrames(anim *gif.GIF, visit func(frame *image.NRGBA) bool) {
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	if bounds.Empty() {
+		bounds = anim.Image[0].Bounds()
+	}
+	canvas := image.NewNRGBA(bounds)
+
+	for i, frame := range anim.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = cloneNRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if !visit(canvas) {
+			return
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+}
+
+// renderAnimation applies transform to every frame of an animated GIF and encodes the result, keeping the timing of
+// the original. The frames are transformed one at a time, so that only the paletted results are kept in memory.
+func renderAnimation(anim *gif.GIF, transform func(image.Image) image.Image) ([]byte, error) {
+	out := &gif.GIF{LoopCount: anim.LoopCount, Delay: anim.Delay}
+	composeFrames(anim, func(frame *image.NRGBA) bool {
+		rendered := transform(frame)
+		paletted := image.NewPaletted(rendered.Bounds(), palette.Plan9)
+		draw.Draw(paletted, paletted.Bounds(), rendered, rendered.Bounds().Min, draw.Src)
+		out.Image = append(out.Image, paletted)
+		out.Disposal = append(out.Disposal, gif.DisposalNone)
+		return true
+	})
+	out.Config = image.Config{
+		ColorModel: color.Palette(palette.Plan9),
+		Width:      out.Image[0].Bounds().Dx(),
+		Height:     out.Image[0].Bounds().Dy(),
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Resize scales the image down to the given width, keeping the aspect ratio. Images already narrower are returned
+// untouched.
+func Resize(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if width <= 0 || width >= b.Dx() {
+		return img
+	}
+	height := maxInt(b.Dy()*width/b.Dx(), 1)
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(out, out.Bounds(), img, b, draw.Src, nil)
+	return out
+}
+
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	out := image.NewNRGBA(img.Bounds())
+	copy(out.Pix, img.Pix)
+	return out
+}
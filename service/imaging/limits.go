@@ -0,0 +1,75 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+)
+
+// MaxPixels is the largest number of pixels of an image accepted by CheckSize, counting every frame of an animated
+// GIF. It bounds the memory needed to decode and render the image.
+const MaxPixels = 50 * 1000 * 1000
+
+// ErrTooLarge is returned by CheckSize for the images with more than MaxPixels pixels.
+var ErrTooLarge = errors.New("the image has too many pixels")
+
+// CheckSize returns ErrTooLarge if the encoded image has more than MaxPixels pixels. Only the headers are read, so it
+// is cheap to call before decoding the image. Data that is not an image passes the check, since it cannot be decoded
+// anyway.
+func CheckSize(data []byte) error {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	frames := 1
+	if format == "gif" {
+		frames = countGIFFrames(data)
+	}
+	if int64(config.Width)*int64(config.Height)*int64(frames) > MaxPixels {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// countGIFFrames returns the number of frames of an encoded GIF by walking its blocks, without decompressing them. If
+// the GIF is malformed, it returns the frames found before the error, which are the ones a decoder would read.
+func countGIFFrames(data []byte) int {
+	// The header is followed by the logical screen descriptor, and by the global color table if its flag is set
+	const headerSize = 13
+	if len(data) < headerSize {
+		return 0
+	}
+	pos := headerSize + colorTableSize(data[10])
+
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // Extension: the label, then the data sub-blocks
+			pos += 2
+		case 0x2c: // Image descriptor: position, size and flags, then the local color table and the LZW code size
+			if pos+10 > len(data) {
+				return frames
+			}
+			frames++
+			pos += 10 + colorTableSize(data[pos+9]) + 1
+		default: // Trailer, or a malformed block
+			return frames
+		}
+
+		// Skip the data sub-blocks, each one prefixed by its size and ended by an empty one
+		for pos < len(data) && data[pos] != 0 {
+			pos += int(data[pos]) + 1
+		}
+		pos++
+	}
+	return frames
+}
+
+// colorTableSize returns the size in bytes of the color table described by the flags of a GIF descriptor.
+func colorTableSize(flags byte) int {
+	if flags&0x80 == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
@@ -0,0 +1,63 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"testing"
+)
+
+// encodeGIF returns a GIF with the given logical screen size and frames of 1x1 pixel, alternating the global color
+// table and a local one
+func encodeGIF(t *testing.T, width, height, frames int) []byte {
+	t.Helper()
+	anim := &gif.GIF{Config: image.Config{Width: width, Height: height, ColorModel: color.Palette(palette.Plan9)}}
+	for i := 0; i < frames; i++ {
+		p := color.Palette(palette.Plan9)
+		if i%2 == 1 {
+			p = color.Palette(palette.WebSafe)
+		}
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), p))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCountGIFFrames(t *testing.T) {
+	for _, frames := range []int{1, 2, 7} {
+		data := encodeGIF(t, 4, 4, frames)
+		if got := countGIFFrames(data); got != frames {
+			t.Errorf("counted %d frames, want %d", got, frames)
+		}
+
+		// A truncated GIF counts the frames before the end
+		if got := countGIFFrames(data[:len(data)/2]); got > frames {
+			t.Errorf("counted %d frames in a truncated GIF of %d", got, frames)
+		}
+	}
+}
+
+func TestCheckSize(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"small animation", encodeGIF(t, 100, 100, 3), nil},
+		{"large still", encodeGIF(t, 7000, 7000, 1), nil},
+		{"too many frames", encodeGIF(t, 5000, 5000, 3), ErrTooLarge},
+		{"too many pixels", encodeGIF(t, 8000, 8000, 1), ErrTooLarge},
+		{"not an image", []byte("not an image"), nil},
+	}
+	for _, tt := range tests {
+		if got := CheckSize(tt.data); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
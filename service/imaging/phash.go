@@ -0,0 +1,80 @@
+/*
+Package imaging contains the image processing used by the API: decoding the uploaded bytes and computing the
+derived data (hashes, renditions) that the handlers need.
+*/
+package imaging
+
+import (
+	"image"
+	"math/bits"
+
+	// Register the decoders for the formats accepted by uploadPhoto
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// hashWidth and hashHeight are the size of the grayscale grid used by PerceptualHash. The grid is one pixel wider than
+// tall because each bit compares two horizontally adjacent cells.
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// PerceptualHash returns the 64-bit difference hash (dHash) of the encoded image. Visually similar images (re-encoded,
+// resized, slightly recolored) produce hashes with a small Hamming distance.
+func PerceptualHash(data []byte) (uint64, error) {
+	img, _, err := Decode(data)
+	if err != nil {
+		return 0, err
+	}
+
+	// Reduce the image to a hashWidth x hashHeight grid of average luminance values
+	var grid [hashHeight][hashWidth]float64
+	var count [hashHeight][hashWidth]int
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return 0, image.ErrFormat
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		gy := (y - b.Min.Y) * hashHeight / b.Dy()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gx := (x - b.Min.X) * hashWidth / b.Dx()
+			r, g, bl, _ := img.At(x, y).RGBA()
+			grid[gy][gx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			count[gy][gx]++
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			left := grid[y][x] / float64(maxInt(count[y][x], 1))
+			right := grid[y][x+1] / float64(maxInt(count[y][x+1], 1))
+			hash <<= 1
+			if left < right {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// HammingDistance returns the number of differing bits between two perceptual hashes.
+func HammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,78 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"net/http"
+)
+
+// RenderOptions describes how a rendition is derived from an original image.
+type RenderOptions struct {
+	// Recipe contains the edits of the owner, nil if the photo has not been edited
+	Recipe *Recipe
+
+	// Width scales the image down to this width after the edits, zero to keep the size
+	Width int
+
+	// Watermark is stamped last, nil for no watermark
+	Watermark *Watermark
+}
+
+// Render derives a rendition from the original encoded image, and returns it with its content type. The original is
+// returned untouched if there is nothing to apply. Animated GIFs are rendered frame by frame, so that they stay
+// animated.
+func Render(original []byte, options RenderOptions) ([]byte, string, error) {
+	contentType := http.DetectContentType(original)
+	if options.Recipe == nil && options.Watermark == nil && options.Width == 0 {
+		return original, contentType, nil
+	}
+
+	if contentType == "image/gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(original))
+		if err != nil {
+			return nil, "", err
+		}
+		if len(anim.Image) > 1 {
+			if err := options.validate(image.Rect(0, 0, anim.Config.Width, anim.Config.Height)); err != nil {
+				return nil, "", err
+			}
+			rendition, err := renderAnimation(anim, options.apply)
+			return rendition, "image/gif", err
+		}
+	}
+
+	img, format, err := Decode(original)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := options.validate(img.Bounds()); err != nil {
+		return nil, "", err
+	}
+	return Encode(options.apply(img), format)
+}
+
+// validate checks that the options can be applied to an image with the given bounds.
+func (options RenderOptions) validate(bounds image.Rectangle) error {
+	if options.Recipe != nil {
+		if err := options.Recipe.Validate(bounds); err != nil {
+			return err
+		}
+	}
+	if options.Watermark != nil {
+		return options.Watermark.Validate()
+	}
+	return nil
+}
+
+// apply transforms a single image, or a single frame of an animation.
+func (options RenderOptions) apply(img image.Image) image.Image {
+	if options.Recipe != nil {
+		img = options.Recipe.Apply(img)
+	}
+	img = Resize(img, options.Width)
+	if options.Watermark != nil {
+		img = options.Watermark.Apply(img)
+	}
+	return img
+}
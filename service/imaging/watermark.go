@@ -0,0 +1,129 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Watermark positions accepted in Watermark.Position
+const (
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+	PositionCenter      = "center"
+)
+
+// Watermark is a visible mark stamped on the served images.
+type Watermark struct {
+	// Text is stamped when Image is nil
+	Text string
+
+	// Image is stamped in place of the text, if not nil
+	Image image.Image
+
+	// Position is one of the Position constants
+	Position string
+
+	// Opacity goes from 0 (invisible) to 1 (opaque)
+	Opacity float64
+}
+
+// ValidPosition checks if the string is one of the watermark positions.
+func ValidPosition(position string) bool {
+	switch position {
+	case PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+		return true
+	}
+	return false
+}
+
+// Validate checks that the watermark can be applied.
+func (wm Watermark) Validate() error {
+	if wm.Image == nil && wm.Text == "" {
+		return errors.New("the watermark needs a text or an image")
+	}
+	if !ValidPosition(wm.Position) {
+		return errors.New("unknown watermark position")
+	}
+	if wm.Opacity < 0 || wm.Opacity > 1 {
+		return errors.New("the opacity must be between 0 and 1")
+	}
+	return nil
+}
+
+// Apply stamps the watermark on a copy of img. Text is scaled to about 5% of the height of the image, images to 20%
+// of its width.
+func (wm Watermark) Apply(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Src)
+
+	mark := wm.Image
+	var width, height int
+	if mark == nil {
+		mark = textMark(wm.Text)
+		height = maxInt(b.Dy()/20, mark.Bounds().Dy())
+		width = mark.Bounds().Dx() * height / mark.Bounds().Dy()
+	} else {
+		width = maxInt(b.Dx()/5, 1)
+		height = maxInt(mark.Bounds().Dy()*width/maxInt(mark.Bounds().Dx(), 1), 1)
+	}
+
+	// Never cover more than the image itself
+	if width > b.Dx() {
+		height = maxInt(height*b.Dx()/width, 1)
+		width = b.Dx()
+	}
+	if height > b.Dy() {
+		width = maxInt(width*b.Dy()/height, 1)
+		height = b.Dy()
+	}
+
+	margin := minInt(b.Dx(), b.Dy()) / 50
+	var x, y int
+	switch wm.Position {
+	case PositionTopLeft:
+		x, y = margin, margin
+	case PositionTopRight:
+		x, y = b.Dx()-width-margin, margin
+	case PositionBottomLeft:
+		x, y = margin, b.Dy()-height-margin
+	case PositionCenter:
+		x, y = (b.Dx()-width)/2, (b.Dy()-height)/2
+	default:
+		x, y = b.Dx()-width-margin, b.Dy()-height-margin
+	}
+
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), mark, mark.Bounds(), draw.Src, nil)
+
+	opacity := image.NewUniform(color.Alpha{A: uint8(wm.Opacity * 255)})
+	draw.DrawMask(out, image.Rect(x, y, x+width, y+height), scaled, image.Point{}, opacity, image.Point{}, draw.Over)
+	return out
+}
+
+// textMark renders the text in white with a dark outline, so that it is readable on any background.
+func textMark(text string) image.Image {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil() + 2
+	height := face.Metrics().Height.Ceil() + 2
+	mark := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	baseline := face.Metrics().Ascent.Ceil() + 1
+	drawer := font.Drawer{Dst: mark, Face: face, Src: image.NewUniform(color.NRGBA{A: 200})}
+	for _, offset := range []image.Point{{0, 1}, {2, 1}, {1, 0}, {1, 2}} {
+		drawer.Dot = fixed.P(offset.X, baseline+offset.Y-1)
+		drawer.DrawString(text)
+	}
+	drawer.Src = image.White
+	drawer.Dot = fixed.P(1, baseline)
+	drawer.DrawString(text)
+	return mark
+}